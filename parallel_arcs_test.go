@@ -0,0 +1,97 @@
+package parallel_testing
+
+import (
+	"bytes"
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+const blockingNet = `{
+  "name": "blocking buffer",
+  "places": [{"name": "source", "mark": 1}, {"name": "buffer", "mark": 0}, {"name": "trigger", "mark": 0}],
+  "transitions": [{"name": "arrive", "mean": 1}, {"name": "flush", "mean": 0}],
+  "arcs": [
+    {"place": "source", "transition": "arrive", "kind": "in"},
+    {"place": "buffer", "transition": "arrive", "kind": "inhibitor", "weight": 3},
+    {"place": "source", "transition": "arrive", "kind": "out"},
+    {"place": "buffer", "transition": "arrive", "kind": "out"},
+    {"place": "trigger", "transition": "flush", "kind": "in"},
+    {"place": "buffer", "transition": "flush", "kind": "reset"}
+  ]
+}`
+
+func TestInhibitorAndResetArcs(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(blockingNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arrive := net.Transitions[net.FindTransitionByName("arrive")]
+	flush := net.Transitions[net.FindTransitionByName("flush")]
+	buffer := net.Places[net.FindPlaceByName("buffer")]
+
+	buffer.SetMark(2)
+	if !arrive.Condition(net.Places) {
+		t.Errorf("arrive is blocked below the inhibitor weight")
+	}
+
+	buffer.SetMark(3)
+	if arrive.Condition(net.Places) {
+		t.Errorf("arrive is enabled with a full buffer")
+	}
+
+	net.Places[net.FindPlaceByName("trigger")].SetMark(1)
+	flush.ActIn(net.Places, 0)
+	if buffer.GetMark() != 0 {
+		t.Errorf("reset arc left %f markers", buffer.GetMark())
+	}
+
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+	sim := (&petri.Simulator{}).Build(net, &c, &gtime, &cond, make(chan int))
+	model := (&petri.Model{}).Build([]*petri.Simulator{sim}, &gtime)
+	model.IsProtocolPrint = false
+	model.GoRun(100)
+
+	if buffer.GetMark() != 3 || buffer.GetObservedMax() != 3 {
+		t.Errorf("buffer ended with %f markers, max %f, want 3", buffer.GetMark(), buffer.GetObservedMax())
+	}
+}
+
+func TestInhibitorAndResetArcsInFiles(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(blockingNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var yaml, pnml bytes.Buffer
+	petri.EncodeNetYAML(&yaml, &net)
+	petri.EncodeNetPNML(&pnml, &net)
+
+	for _, want := range []string{"kind: inhibitor", "kind: reset"} {
+		if !strings.Contains(yaml.String(), want) {
+			t.Errorf("yaml has no %s:\n%s", want, yaml.String())
+		}
+	}
+
+	loaded, err := petri.DecodeNetPNML(&pnml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := loaded.LinksIn[1]; !l.IsInhibitor() || l.GetQuantity() != 3 {
+		t.Errorf("inhibitor arc lost in pnml: %+v", l)
+	}
+
+	if l := loaded.LinksIn[3]; !l.IsReset() {
+		t.Errorf("reset arc lost in pnml: %+v", l)
+	}
+
+	var dot bytes.Buffer
+	petri.WriteNetDot(&dot, &net)
+	if !strings.Contains(dot.String(), "arrowhead=odot") || !strings.Contains(dot.String(), "arrowhead=normalnormal") {
+		t.Errorf("inhibitor and reset arcs are not drawn:\n%s", dot.String())
+	}
+}
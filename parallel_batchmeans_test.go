@@ -0,0 +1,136 @@
+package parallel_testing
+
+import (
+	"context"
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"testing"
+)
+
+func batchModel(gtime *petri.GlobalTime) *petri.Model {
+	var c petri.GlobalCounter
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestSerial(3, 2, &c, gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(21)
+	return model
+}
+
+func TestBatchMeans(t *testing.T) {
+	var gtime petri.GlobalTime
+	model := batchModel(&gtime)
+	group := model.Objects[1]
+
+	batches := (&petri.BatchMeans{}).Build(model, 500)
+	if err := batches.AddPlace(group, "P1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := batches.AddTransition(group, "T0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := batches.AddPlace(group, "nothing"); err == nil {
+		t.Error("added a place the object doesn't have")
+	}
+	model.GoRun(20000)
+
+	free := batches.Places[0]
+	if len(free.Means) != 40 || free.N != 40 {
+		t.Fatalf("%d batches, want 40", len(free.Means))
+	}
+
+	// the batches cover the run, so their mean is the time-weighted mean of the place
+	if math.Abs(free.Mean-group.Places[1].Mean) > 1e-3 {
+		t.Errorf("mean of batches %f, mean of the place %f", free.Mean, group.Places[1].Mean)
+	}
+	if free.HalfWidth <= 0 || free.Low() > free.Mean || free.Lag1 < -1 || free.Lag1 > 1 {
+		t.Errorf("free channels %v with lag-1 autocorrelation %f", free.Estimate, free.Lag1)
+	}
+
+	busy := batches.Transitions[0]
+	if math.Abs(busy.Mean+free.Mean-1) > 1e-9 {
+		t.Errorf("channel busy %f and free %f of the time", busy.Mean, free.Mean)
+	}
+
+	if batches.StopTime != 0 {
+		t.Errorf("run without precision stopped at %f", batches.StopTime)
+	}
+}
+
+func TestBatchMeansPrecision(t *testing.T) {
+	var gtime petri.GlobalTime
+	model := batchModel(&gtime)
+	batches := (&petri.BatchMeans{Precision: 0.01}).Build(model.SetWarmUp(100), 200)
+	if err := batches.AddPlace(model.Objects[1], "P1"); err != nil {
+		t.Fatal(err)
+	}
+	model.GoRun(1e6)
+
+	free := batches.Places[0]
+	if batches.StopTime == 0 || model.T > batches.StopTime+200 || !batches.Reached() {
+		t.Fatalf("run went on to %f, precision reached at %f", model.T, batches.StopTime)
+	}
+	if free.Relative() > 0.01 || free.N < 10 || !free.Independent {
+		t.Errorf("stopped with %v, lag-1 autocorrelation %f", free.Estimate, free.Lag1)
+	}
+	if batches.Start != 100 {
+		t.Errorf("batches start at %f, want the warm-up", batches.Start)
+	}
+}
+
+func TestBatchMeansParallel(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestParallel(3, 2, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(21)
+
+	batches := (&petri.BatchMeans{}).Build(model, 100)
+	for _, obj := range model.Objects[1:] {
+		if err := batches.AddPlace(obj, "P1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := batches.AddTransition(obj, "T1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	portRun(t, model, 2000)
+
+	for _, s := range append(batches.Places, batches.Transitions...) {
+		if len(s.Means) != 20 {
+			t.Errorf("%s of %s: %d batches, want 20", s.Name, s.Object, len(s.Means))
+		}
+	}
+}
+
+func TestBatchMeansConstant(t *testing.T) {
+	var gtime petri.GlobalTime
+	batches := (&petri.BatchMeans{Precision: 0.01}).Build(batchModel(&gtime), 100)
+
+	// batch means that don't vary give a zero half width, not a precise estimate
+	constant := &petri.BatchSeries{Means: make([]float64, 20), Estimate: petri.Estimate{N: 20, Mean: 1}, Independent: true}
+	batches.Places = append(batches.Places, constant)
+	if !math.IsInf(constant.Relative(), 1) || batches.Reached() {
+		t.Errorf("constant series with relative half width %f reached the precision", constant.Relative())
+	}
+}
+
+func TestBatchMeansTimeWarp(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestParallel(3, 2, &c, &gtime, &cond, make(chan int))
+	batches := (&petri.BatchMeans{}).Build(model, 100)
+	if err := batches.AddPlace(model.Objects[1], "P1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// rolled back steps would stay in the batches
+	warp := (&petri.TimeWarp{}).Build(model.Objects, 1000)
+	if err := warp.Run(context.Background()); err == nil {
+		t.Error("time warp ran objects with batch means")
+	}
+}
@@ -0,0 +1,145 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+const tiesNet = `{
+  "name": "ties",
+  "places": [
+    {"name": "jobs A", "mark": 2, "coloured": true}, {"name": "jobs B", "mark": 1},
+    {"name": "done", "coloured": true}
+  ],
+  "transitions": [
+    {"name": "b", "mean": 2}, {"name": "a", "mean": 2}
+  ],
+  "arcs": [
+    {"place": "jobs A", "transition": "a", "kind": "in"},
+    {"place": "done", "transition": "a", "kind": "out"},
+    {"place": "jobs B", "transition": "b", "kind": "in"},
+    {"place": "done", "transition": "b", "kind": "out"}
+  ]
+}`
+
+func buildTies(t *testing.T) *petri.Simulator {
+	net, err := petri.DecodeNetJSON(strings.NewReader(tiesNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	return (&petri.Simulator{}).Build(net, &c, &gtime, &cond, make(chan int))
+}
+
+func TestCalendarTies(t *testing.T) {
+	sim := buildTies(t)
+
+	var order []string
+	for _, tr := range sim.Transitions {
+		tr.AddActOutHook(func(tr *petri.Transition, places []*petri.Place, currentTime float64) {
+			order = append(order, tr.Name)
+		})
+	}
+
+	sim.Input()
+	if sim.TimeMin != 2 || sim.EventMin.Name != "b" {
+		t.Fatalf("next event %v at %f, want b at 2", sim.EventMin, sim.TimeMin)
+	}
+
+	a := sim.Transitions[sim.TNet.FindTransitionByName("a")]
+	if got := a.Timeouts(); len(got) != 2 || got[0] != 2 || got[1] != 2 {
+		t.Errorf("timeouts of a %v, want [2 2]", got)
+	}
+	if a.Timeout() != 2 || a.IMultiChannel() != 0 {
+		t.Errorf("closest end of service of a %f in channel %d, want 2 in 0", a.Timeout(), a.IMultiChannel())
+	}
+
+	// the first transition of the net goes first, a channel scheduled earlier before a later one
+	sim.Gtime.CurrentTime = sim.TimeMin
+	sim.TimeLocal = sim.TimeMin
+	sim.Output()
+	if strings.Join(order, " ") != "b a a" {
+		t.Errorf("output order %v, want b a a", order)
+	}
+
+	// b makes a new token, a passes on those of jobs A
+	done := sim.Places[sim.TNet.FindPlaceByName("done")]
+	for i := 2; i < len(done.Tokens); i++ {
+		if done.Tokens[i].ID < done.Tokens[i-1].ID {
+			t.Errorf("tokens left a out of order: %d before %d", done.Tokens[i-1].ID, done.Tokens[i].ID)
+		}
+	}
+
+	if sim.GetEventMin() != nil {
+		t.Errorf("calendar not empty after all channels are free")
+	}
+}
+
+func TestCalendarCancel(t *testing.T) {
+	sim := buildTies(t)
+	sim.Input()
+
+	a := sim.Transitions[sim.TNet.FindTransitionByName("a")]
+	jobs := sim.Places[sim.TNet.FindPlaceByName("jobs A")]
+	events := a.Events()
+	first := events[0]
+	if first.Time != 2 {
+		t.Fatalf("event at %f, want 2", first.Time)
+	}
+
+	for _, e := range events {
+		a.Cancel(e, sim.Places)
+	}
+	a.Cancel(first, sim.Places) // already gone
+
+	if a.Buffer != 0 || jobs.GetMark() != 2 || len(jobs.Tokens) != 2 {
+		t.Errorf("after cancel buffer %d, jobs A %f with %d tokens, want 0, 2 and 2", a.Buffer, jobs.GetMark(), len(jobs.Tokens))
+	}
+
+	if e := sim.GetEventMin(); e == nil || e.Name != "b" {
+		t.Errorf("next event %v, want b", e)
+	}
+}
+
+func TestCalendarCancelReset(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(`{
+  "name": "flush",
+  "places": [{"name": "trigger", "mark": 1}, {"name": "queue", "mark": 3, "coloured": true}, {"name": "out"}],
+  "transitions": [{"name": "flush", "mean": 1}],
+  "arcs": [
+    {"place": "trigger", "transition": "flush", "kind": "in"},
+    {"place": "queue", "transition": "flush", "kind": "reset"},
+    {"place": "out", "transition": "flush", "kind": "out"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	sim := (&petri.Simulator{}).Build(net, &c, &gtime, nil, nil)
+	flush := sim.Transitions[0]
+	queue := sim.Places[sim.TNet.FindPlaceByName("queue")]
+	ids := []int64{queue.Tokens[0].ID, queue.Tokens[1].ID, queue.Tokens[2].ID}
+	sim.Input()
+	if queue.GetMark() != 0 || flush.Buffer != 1 {
+		t.Fatalf("queue holds %f after the reset", queue.GetMark())
+	}
+
+	// the emptied queue comes back in order together with the trigger
+	flush.Cancel(flush.Events()[0], sim.Places)
+	if queue.GetMark() != 3 || len(queue.Tokens) != 3 || sim.Places[0].GetMark() != 1 {
+		t.Fatalf("after cancel queue %f with %d tokens, trigger %f", queue.GetMark(), len(queue.Tokens), sim.Places[0].GetMark())
+	}
+	for i, token := range queue.Tokens {
+		if token.ID != ids[i] {
+			t.Errorf("token %d is %d, was %d", i, token.ID, ids[i])
+		}
+	}
+}
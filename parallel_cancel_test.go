@@ -0,0 +1,135 @@
+package parallel_testing
+
+import (
+	"context"
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunContextDeadline(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestParallel(2, 1, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	gtime.ModTime = 100
+
+	// the generator never runs, the group waits for its markers
+	group := model.Objects[1]
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := group.RunContext(ctx)
+	if err == nil {
+		t.Fatal("run ended without markers")
+	}
+
+	want := "object group_0 stopped at time 0.000000 waiting for markers from " + model.Objects[0].Name + ": context deadline exceeded"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+
+	if group.Waiting() != "" {
+		t.Errorf("stopped object still waits for %s", group.Waiting())
+	}
+}
+
+func TestModelRunContext(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestParallel(4, 3, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	gtime.ModTime = 10000
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := model.RunContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	last := model.Objects[len(model.Objects)-1]
+	if sink := last.Places[len(last.Places)-1]; sink.Mark == 0 {
+		t.Errorf("no markers went through the chain of %d objects", len(model.Objects))
+	}
+}
+
+func TestGoRunContextCancelled(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestSerial(3, 2, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := model.GoRunContext(ctx, 1000)
+	if err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("cancelled run returned %v", err)
+	}
+
+	if err := model.GoRunContext(context.Background(), 100); err != nil {
+		t.Errorf("run without deadline failed: %v", err)
+	}
+}
+
+func TestParallelGoContext(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	serial := GetModelSMOGroupForTestSerial(3, 2, &c, &gtime, &cond, make(chan int))
+	serial.IsProtocolPrint = false
+	serial.SetSeed(11)
+	serial.GoRun(1000)
+
+	// the objects share places, so they enter markers one after another
+	var pc petri.GlobalCounter
+	var pgtime petri.GlobalTime
+	model := GetModelSMOGroupForTestSerial(3, 2, &pc, &pgtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(11)
+	if err := model.ParallelGoContext(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last markers aren't entered into transitions yet, the means are the same
+	for i, obj := range model.Objects {
+		for k, p := range obj.Places {
+			if other := serial.Objects[i].Places[k]; p.Mean != other.Mean {
+				t.Errorf("%s of %s: mean %f, serial run %f", p.Name, obj.Name, p.Mean, other.Mean)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := model.ParallelGoContext(ctx, 1000)
+	if err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("cancelled run returned %v", err)
+	}
+}
+
+func TestRunContextSharedPlaces(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	// objects sharing places without ports would race on them
+	model := GetModelSMOGroupForTestSerial(2, 1, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	gtime.ModTime = 100
+
+	if err := model.RunContext(context.Background()); err == nil {
+		t.Error("objects sharing places ran in parallel")
+	}
+	if err := (&petri.Conservative{}).Build(model.Objects, 100).Run(context.Background()); err == nil {
+		t.Error("conservative run took objects sharing places")
+	}
+}
@@ -0,0 +1,199 @@
+package parallel_testing
+
+import (
+	"context"
+	"fmt"
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func conservativeRun(t *testing.T, model *petri.Model, timeModeling float64) *petri.Conservative {
+	cons := (&petri.Conservative{}).Build(model.Objects, timeModeling)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cons.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return cons
+}
+
+func TestConservativeChain(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	serial := GetModelSMOGroupForTestSerial(4, 3, &c, &gtime, &cond, make(chan int))
+	serial.IsProtocolPrint = false
+	serial.SetSeed(5)
+	serial.GoRun(1000)
+
+	var pc petri.GlobalCounter
+	var pgtime petri.GlobalTime
+	parallel := GetModelSMOGroupForTestParallel(4, 3, &pc, &pgtime, &cond, make(chan int))
+	parallel.SetSeed(5)
+	cons := conservativeRun(t, parallel, 1000)
+	if len(cons.Ports) != 3 || cons.Messages == 0 {
+		t.Fatalf("%d ports carried %d messages, want 3 ports", len(cons.Ports), cons.Messages)
+	}
+
+	// streams of transitions don't depend on the engine, so neither do the runs
+	for i, obj := range serial.Objects {
+		for k, tr := range obj.Transitions {
+			other := parallel.Objects[i].Transitions[k]
+			if tr.Buffer != other.Buffer || math.Abs(tr.Mean-other.Mean) > 1e-9 {
+				t.Errorf("object %s transition %s: buffer %d and mean %f, serial run %d and %f",
+					obj.Name, tr.Name, other.Buffer, other.Mean, tr.Buffer, tr.Mean)
+			}
+		}
+	}
+
+	last := len(serial.Objects) - 1
+	sink := len(serial.Objects[last].Places) - 1
+	if serial.Objects[last].Places[sink].Mark != parallel.Objects[last].Places[sink].Mark {
+		t.Errorf("%f markers served, serial run %f", parallel.Objects[last].Places[sink].Mark, serial.Objects[last].Places[sink].Mark)
+	}
+}
+
+// ringNet passes jobs on to the next object of a ring
+func ringNet(name string, jobs int, delay string) petri.Net {
+	net, err := petri.DecodeNetJSON(strings.NewReader(fmt.Sprintf(`{
+  "name": %q,
+  "places": [{"name": "in", "mark": %d}, {"name": "out"}],
+  "transitions": [{"name": "work", "mean": 1%s}],
+  "arcs": [
+    {"place": "in", "transition": "work", "kind": "in"},
+    {"place": "out", "transition": "work", "kind": "out"}
+  ]
+}`, name, jobs, delay)))
+	if err != nil {
+		panic(err)
+	}
+
+	return net
+}
+
+// ringModel is a ring of objects sharing places for serial runs, see linkedRing
+func ringModel(delay string, gtime *petri.GlobalTime) *petri.Model {
+	var c petri.GlobalCounter
+	var cond petri.GlobalLocker
+
+	var objects []*petri.Simulator
+	for i := 0; i < 3; i++ {
+		objects = append(objects, (&petri.Simulator{}).Build(ringNet(fmt.Sprintf("ring_%d", i), 2*i, delay), &c, gtime, &cond, make(chan int)))
+	}
+
+	for i, obj := range objects {
+		next := objects[(i+1)%len(objects)]
+		obj.Places[1] = next.Places[0]
+	}
+
+	model := (&petri.Model{}).Build(objects, gtime)
+	model.IsProtocolPrint = false
+	return model
+}
+
+// linkedRing is the ring of ringModel with ports between the objects
+func linkedRing(delay string, gtime *petri.GlobalTime) *petri.Model {
+	model := ringModel(delay, gtime)
+	if err := model.ConnectShared(); err != nil {
+		panic(err)
+	}
+
+	return model
+}
+
+func TestConservativeRing(t *testing.T) {
+	for _, delay := range []string{"", `, "distribution": "exp"`} {
+		var gtime petri.GlobalTime
+		serial := ringModel(delay, &gtime)
+		serial.SetSeed(9)
+		serial.GoRun(200)
+
+		var pgtime petri.GlobalTime
+		parallel := linkedRing(delay, &pgtime)
+		parallel.SetSeed(9)
+		cons := conservativeRun(t, parallel, 200)
+
+		jobs := 0.0
+		for i, obj := range parallel.Objects {
+			jobs += obj.Places[0].Mark + float64(obj.Transitions[0].Buffer)
+			if obj.Places[0].Mark != serial.Objects[i].Places[0].Mark {
+				t.Errorf("delay%s: %s holds %f jobs, serial run %f", delay, obj.Name, obj.Places[0].Mark, serial.Objects[i].Places[0].Mark)
+			}
+		}
+
+		if jobs != 6 {
+			t.Errorf("delay%s: %f jobs in the ring, want 6", delay, jobs)
+		}
+
+		// without lookahead only deadlock recovery moves the ring on
+		if delay != "" && (cons.Recoveries == 0 || len(cons.ZeroLookahead) != 3) {
+			t.Errorf("ring with exponential delays ran with %d recoveries and %d ports without lookahead",
+				cons.Recoveries, len(cons.ZeroLookahead))
+		}
+		if delay == "" && len(cons.ZeroLookahead) != 0 {
+			t.Errorf("ring with constant delays has %d ports without lookahead", len(cons.ZeroLookahead))
+		}
+	}
+}
+
+func TestConservativeLookahead(t *testing.T) {
+	// traces tell the shortest delay, so the ports of the ring get a lookahead
+	traced := func(gtime *petri.GlobalTime) *petri.Model {
+		model := ringModel("", gtime)
+		r := rand.New(rand.NewSource(6))
+		for _, obj := range model.Objects {
+			delays := make([]float64, 40)
+			for i := range delays {
+				delays[i] = 0.5 + 2*r.Float64()
+			}
+			obj.Transitions[0].SetDelay(&petri.Trace{Delays: delays, Cycle: true})
+		}
+
+		return model
+	}
+
+	var gtime petri.GlobalTime
+	serial := traced(&gtime)
+	serial.GoRun(200)
+
+	var pgtime petri.GlobalTime
+	parallel := traced(&pgtime)
+	if err := parallel.ConnectShared(); err != nil {
+		t.Fatal(err)
+	}
+	cons := conservativeRun(t, parallel, 200)
+
+	for _, p := range cons.Ports {
+		if p.Lookahead < 0.5 {
+			t.Errorf("port %s -> %s has lookahead %f, the trace never goes below 0.5", p.From.Name, p.To.Name, p.Lookahead)
+		}
+	}
+	if len(cons.ZeroLookahead) != 0 || cons.NullMessages == 0 {
+		t.Errorf("%d ports without lookahead, %d null messages", len(cons.ZeroLookahead), cons.NullMessages)
+	}
+
+	for i, obj := range parallel.Objects {
+		if obj.Places[0].Mark != serial.Objects[i].Places[0].Mark {
+			t.Errorf("%s holds %f jobs, serial run %f", obj.Name, obj.Places[0].Mark, serial.Objects[i].Places[0].Mark)
+		}
+	}
+}
+
+func TestConservativeCancel(t *testing.T) {
+	var gtime petri.GlobalTime
+	model := linkedRing("", &gtime)
+	cons := (&petri.Conservative{}).Build(model.Objects, 1e9)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := cons.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+		t.Errorf("run past the deadline returned %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package parallel_testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+func TestCoverabilityUnbounded(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetGenerator(100, 1, "", &c)
+
+	tree, err := net.CoverabilityTree(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u := tree.Unbounded(); len(u) != 1 || u[0] != "P1" {
+		t.Errorf("unbounded places %v, want [P1]", u)
+	}
+
+	bounds := tree.Bounds()
+	if bounds[0].Bound != 1 || !bounds[1].Unbounded() {
+		t.Errorf("bounds %v", bounds)
+	}
+
+	var js bytes.Buffer
+	if err := petri.EncodeCoverabilityJSON(&js, tree); err != nil || !strings.Contains(js.String(), `"ω"`) {
+		t.Fatalf("omega is not encoded: %v\n%s", err, js.String())
+	}
+
+	var decoded petri.CoverabilityTree
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || decoded.Bounded() {
+		t.Errorf("json doesn't decode back: %v", err)
+	}
+}
+
+func TestCoverabilityBounded(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(2, 1, 1.0, "smo group", &c)
+	net.Places[0].SetMark(2)
+
+	tree, err := net.CoverabilityTree(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []float64{2, 1, 2, 1, 2}
+	for i, b := range tree.Bounds() {
+		if b.Bound != want[i] {
+			t.Errorf("%v, want %f", b, want[i])
+		}
+	}
+
+	var dot bytes.Buffer
+	petri.WriteCoverabilityDot(&dot, tree)
+	if !tree.Bounded() || !strings.Contains(dot.String(), "dashed") {
+		t.Errorf("unexpected tree:\n%s", dot.String())
+	}
+}
+
+func TestCoverabilityInhibitor(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(`{
+  "name": "blocked generator",
+  "places": [{"name": "source", "mark": 1}, {"name": "queue"}, {"name": "stop"}],
+  "transitions": [{"name": "arrive", "mean": 1}],
+  "arcs": [
+    {"place": "source", "transition": "arrive", "kind": "in"},
+    {"place": "source", "transition": "arrive", "kind": "out"},
+    {"place": "queue", "transition": "arrive", "kind": "out"},
+    {"place": "stop", "transition": "arrive", "kind": "inhibitor"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := net.CoverabilityTree(0)
+	if err == nil || !tree.Approximate {
+		t.Errorf("tree of a net with an inhibitor arc without error: %v", tree.Bounds())
+	}
+
+	// the reachability graph is too large and the tree can't tell dead transitions
+	if _, err := net.CheckLiveness(50); err == nil || !strings.Contains(err.Error(), "inhibitor") {
+		t.Errorf("liveness decided from an approximate tree: %v", err)
+	}
+}
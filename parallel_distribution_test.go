@@ -0,0 +1,119 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistributionMeans(t *testing.T) {
+	cases := []struct {
+		name   string
+		params map[string]float64
+		mean   float64
+	}{
+		{"const", map[string]float64{"mean": 2}, 2},
+		{"exp", map[string]float64{"mean": 2}, 2},
+		{"unif", map[string]float64{"min": 1, "max": 3}, 2},
+		{"norm", map[string]float64{"mean": 2, "deviation": 0.5}, 2},
+		{"truncnorm", map[string]float64{"mean": 0, "deviation": 1}, math.Sqrt(2 / math.Pi)},
+		{"erlang", map[string]float64{"k": 3, "mean": 2}, 2},
+		{"gamma", map[string]float64{"shape": 0.5, "scale": 4}, 2},
+		{"gamma", map[string]float64{"shape": 4, "scale": 0.5}, 2},
+		{"lognorm", map[string]float64{"mean": 2, "deviation": 1}, 2},
+		{"weibull", map[string]float64{"shape": 1, "scale": 2}, 2},
+		{"triang", map[string]float64{"min": 0, "mode": 3, "max": 3}, 2},
+		{"hyperexp", map[string]float64{"p": 0.5, "mean1": 1, "mean2": 3}, 2},
+		{"empiric", map[string]float64{"x0": 1, "p0": 0, "x1": 2, "p1": 0.5, "x2": 3, "p2": 1}, 2},
+	}
+
+	for _, c := range cases {
+		d, err := petri.CreateDistribution(c.name, c.params)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		r := rand.New(rand.NewSource(1))
+		sum := 0.0
+		n := 50000
+		for i := 0; i < n; i++ {
+			v := d.Sample(r)
+			if c.name != "norm" && v < 0 {
+				t.Fatalf("%s: negative delay %f", c.name, v)
+			}
+			sum += v
+		}
+
+		if mean := sum / float64(n); math.Abs(mean-c.mean) > 0.05*c.mean {
+			t.Errorf("%s %v: mean %f, want %f", c.name, c.params, mean, c.mean)
+		}
+	}
+}
+
+func TestDistributionErrors(t *testing.T) {
+	if _, err := petri.CreateDistribution("expo", map[string]float64{"mean": 1}); err == nil {
+		t.Errorf("unknown distribution accepted")
+	}
+
+	if _, err := petri.CreateDistribution("erlang", map[string]float64{"k": 1.5, "mean": 1}); err == nil {
+		t.Errorf("fractional erlang k accepted")
+	}
+
+	for _, mean := range []float64{0, -1} {
+		if _, err := petri.CreateDistribution("exp", map[string]float64{"mean": mean}); err == nil {
+			t.Errorf("exp with mean %f accepted", mean)
+		}
+	}
+
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(1, 1, 1.0, "typo", &c)
+	net.Transitions[0].SetDistribution("expp", 1.0)
+	if !net.Validate().HasErrors() {
+		t.Errorf("typo in distribution name is not an error")
+	}
+}
+
+func TestDistributionIncomplete(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(1, 1, 1.0, "weibull", &c)
+	tr := net.Transitions[0]
+	tr.SetDistribution("weibull", 2.0).SetParam("shape", 2)
+	if tr.DistributionError() == nil || !net.Validate().HasErrors() {
+		t.Errorf("weibull without scale is not an error")
+	}
+
+	// the run doesn't stop, the transition serves in the mean time
+	if v := tr.GenerateTimeServing(); v != 2 {
+		t.Errorf("weibull without scale gave %f, want the mean 2", v)
+	}
+	runNet(net, 10)
+
+	tr.SetParam("scale", 1)
+	if err := tr.DistributionError(); err != nil {
+		t.Errorf("weibull with shape and scale: %v", err)
+	}
+	if v := tr.GenerateTimeServing(); v == 2 {
+		t.Errorf("weibull with shape and scale gave the mean")
+	}
+}
+
+func TestCustomDistribution(t *testing.T) {
+	petri.RegisterDistribution("twice", func(p map[string]float64) (petri.Distribution, error) {
+		mean := p["mean"]
+		return petri.DistributionFunc(func(petri.RandomStream) float64 { return 2 * mean }), nil
+	})
+
+	var c petri.GlobalCounter
+	tr := (&petri.Transition{}).Build("T0", 1.5, 1, &c)
+	tr.SetDistribution("twice", 1.5)
+	if v := tr.GenerateTimeServing(); v != 3 {
+		t.Errorf("custom distribution gave %f, want 3", v)
+	}
+
+	tr.SetDistribution("weibull", 0).SetParam("shape", 2).SetParam("scale", 1)
+	if v := tr.GenerateTimeServing(); v <= 0 {
+		t.Errorf("weibull gave %f", v)
+	}
+}
@@ -0,0 +1,64 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"testing"
+)
+
+// activeByScan checks every transition, as FindActiveTransition did before it kept the enabled set
+func activeByScan(sim *petri.Simulator) map[*petri.Transition]bool {
+	active := make(map[*petri.Transition]bool)
+	for _, t := range sim.Transitions {
+		if t.Probability != 0 && t.Condition(sim.Places) {
+			active[t] = true
+		}
+	}
+
+	return active
+}
+
+func TestEnablingSet(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestSerial(3, 100, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(3)
+
+	// block every other stage of the last group for a while
+	last := model.Objects[len(model.Objects)-1]
+	for i, tr := range last.Transitions {
+		if i%2 == 1 {
+			tr.SetGuard(func(places []*petri.Place) bool {
+				return gtime.CurrentTime > 200
+			})
+		}
+	}
+
+	checks := 0
+	for _, obj := range model.Objects {
+		sim := obj
+		for _, tr := range sim.Transitions {
+			tr.AddActInHook(func(tr *petri.Transition, places []*petri.Place, currentTime float64) {
+				want := activeByScan(sim)
+				got := sim.FindActiveTransition()
+				if len(got) != len(want) {
+					t.Fatalf("object %s at %f: %d transitions enabled, a scan finds %d", sim.Name, currentTime, len(got), len(want))
+				}
+
+				for _, a := range got {
+					if !want[a] {
+						t.Fatalf("object %s at %f: transition %s isn't enabled", sim.Name, currentTime, a.Name)
+					}
+				}
+				checks++
+			})
+		}
+	}
+
+	model.GoRun(500)
+	if checks == 0 {
+		t.Fatal("no transition fired")
+	}
+}
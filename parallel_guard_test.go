@@ -0,0 +1,98 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+const routingNet = `{
+  "name": "routing",
+  "places": [
+    {"name": "source", "mark": 1}, {"name": "queue"},
+    {"name": "queue A"}, {"name": "free A", "mark": 1},
+    {"name": "queue B"}, {"name": "free B", "mark": 1},
+    {"name": "done"}
+  ],
+  "transitions": [
+    {"name": "arrive", "mean": 1},
+    {"name": "to A"}, {"name": "to B"},
+    {"name": "serve A", "mean": 4}, {"name": "serve B", "mean": 2}
+  ],
+  "arcs": [
+    {"place": "source", "transition": "arrive", "kind": "in"},
+    {"place": "source", "transition": "arrive", "kind": "out"},
+    {"place": "queue", "transition": "arrive", "kind": "out"},
+    {"place": "queue", "transition": "to A", "kind": "in"},
+    {"place": "queue A", "transition": "to A", "kind": "out"},
+    {"place": "queue", "transition": "to B", "kind": "in"},
+    {"place": "queue B", "transition": "to B", "kind": "out"},
+    {"place": "queue A", "transition": "serve A", "kind": "in"},
+    {"place": "free A", "transition": "serve A", "kind": "in"},
+    {"place": "free A", "transition": "serve A", "kind": "out"},
+    {"place": "done", "transition": "serve A", "kind": "out"},
+    {"place": "queue B", "transition": "serve B", "kind": "in"},
+    {"place": "free B", "transition": "serve B", "kind": "in"},
+    {"place": "free B", "transition": "serve B", "kind": "out"},
+    {"place": "done", "transition": "serve B", "kind": "out"}
+  ]
+}`
+
+func TestGuardsAndHooks(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(routingNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toA := net.Transitions[net.FindTransitionByName("to A")]
+	toB := net.Transitions[net.FindTransitionByName("to B")]
+
+	// route to the shorter queue, A wins ties
+	toA.SetGuard(func(places []*petri.Place) bool {
+		return petri.MarkOf(places, "queue A") <= petri.MarkOf(places, "queue B")
+	})
+	toB.SetGuard(func(places []*petri.Place) bool {
+		return petri.MarkOf(places, "queue B") < petri.MarkOf(places, "queue A")
+	})
+
+	routed := map[string]int{}
+	wrong := 0
+	toB.AddActInHook(func(tr *petri.Transition, places []*petri.Place, currentTime float64) {
+		if petri.MarkOf(places, "queue B") >= petri.MarkOf(places, "queue A") {
+			wrong++
+		}
+	})
+
+	count := func(tr *petri.Transition, places []*petri.Place, currentTime float64) {
+		routed[tr.Name]++
+	}
+	toA.AddActOutHook(count)
+	toB.AddActOutHook(count)
+
+	var finished []float64
+	for _, name := range []string{"serve A", "serve B"} {
+		net.Transitions[net.FindTransitionByName(name)].AddActOutHook(func(tr *petri.Transition, places []*petri.Place, currentTime float64) {
+			finished = append(finished, currentTime)
+		})
+	}
+
+	runNet(net, 100)
+
+	if wrong > 0 {
+		t.Errorf("to B fired %d times against its guard", wrong)
+	}
+
+	if routed["to A"] == 0 || routed["to B"] == 0 {
+		t.Errorf("both servers are expected to get customers, got %v", routed)
+	}
+
+	if len(finished) != int(net.GetCurrentMark("done")) {
+		t.Errorf("hooks saw %d departures, done has %f", len(finished), net.GetCurrentMark("done"))
+	}
+
+	for i := 1; i < len(finished); i++ {
+		if finished[i] < finished[i-1] {
+			t.Fatalf("departure times go back: %v", finished)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package parallel_testing
+
+import (
+	"fmt"
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+func invariantStrings(vs []petri.Invariant) string {
+	var s []string
+	for _, v := range vs {
+		s = append(s, v.String())
+	}
+
+	return strings.Join(s, "; ")
+}
+
+func TestInvariants(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(2, 1, 1.0, "smo group", &c)
+
+	m := net.Incidence()
+	if fmt.Sprint(m.Matrix()) != "[[-1 0] [0 0] [1 -1] [0 0] [0 1]]" {
+		t.Errorf("incidence matrix %v", m.Matrix())
+	}
+
+	if p := invariantStrings(m.PInvariants()); p != "P1; P3; P0 + P2 + P4" {
+		t.Errorf("P-invariants %s", p)
+	}
+
+	if tv := m.TInvariants(); len(tv) != 0 {
+		t.Errorf("T-invariants %s in a net without cycles", invariantStrings(tv))
+	}
+
+	cycle, err := petri.DecodeNetJSON(strings.NewReader(`{
+	  "name": "cycle",
+	  "places": [{"name": "idle", "mark": 2}, {"name": "busy"}],
+	  "transitions": [{"name": "start"}, {"name": "stop"}],
+	  "arcs": [
+	    {"place": "idle", "transition": "start", "kind": "in", "weight": 2},
+	    {"place": "busy", "transition": "start", "kind": "out"},
+	    {"place": "busy", "transition": "stop", "kind": "in"},
+	    {"place": "idle", "transition": "stop", "kind": "out", "weight": 2}
+	  ]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m = cycle.Incidence()
+	if p, tv := invariantStrings(m.PInvariants()), invariantStrings(m.TInvariants()); p != "idle + 2*busy" || tv != "start + stop" {
+		t.Errorf("P-invariants %s, T-invariants %s", p, tv)
+	}
+}
+
+func TestInvariantCheck(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestSerial(3, 3, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(7).SetInvariantCheck(true)
+	model.GoRun(500)
+
+	// a hook that makes a channel out of nothing breaks P1
+	var fresh petri.GlobalTime
+	model = GetModelSMOGroupForTestSerial(2, 1, &c, &fresh, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetInvariantCheck(true)
+	model.Objects[1].Transitions[0].AddActOutHook(func(tr *petri.Transition, places []*petri.Place, currentTime float64) {
+		places[1].IncrMark(1)
+	})
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "invariant P1 changed") {
+			t.Errorf("broken invariant not detected: %v", r)
+		}
+	}()
+	model.GoRun(500)
+}
@@ -0,0 +1,108 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+// two processes taking two resources in opposite order
+const resourcesNet = `{
+  "name": "resources",
+  "places": [
+    {"name": "a idle", "mark": 1}, {"name": "a has r1"},
+    {"name": "b idle", "mark": 1}, {"name": "b has r2"},
+    {"name": "r1", "mark": 1}, {"name": "r2", "mark": 1}
+  ],
+  "transitions": [{"name": "a takes r1"}, {"name": "a takes r2"}, {"name": "b takes r2"}, {"name": "b takes r1"}],
+  "arcs": [
+    {"place": "a idle", "transition": "a takes r1", "kind": "in"},
+    {"place": "r1", "transition": "a takes r1", "kind": "in"},
+    {"place": "a has r1", "transition": "a takes r1", "kind": "out"},
+    {"place": "a has r1", "transition": "a takes r2", "kind": "in"},
+    {"place": "r2", "transition": "a takes r2", "kind": "in"},
+    {"place": "a idle", "transition": "a takes r2", "kind": "out"},
+    {"place": "r1", "transition": "a takes r2", "kind": "out"},
+    {"place": "r2", "transition": "a takes r2", "kind": "out"},
+    {"place": "b idle", "transition": "b takes r2", "kind": "in"},
+    {"place": "r2", "transition": "b takes r2", "kind": "in"},
+    {"place": "b has r2", "transition": "b takes r2", "kind": "out"},
+    {"place": "b has r2", "transition": "b takes r1", "kind": "in"},
+    {"place": "r1", "transition": "b takes r1", "kind": "in"},
+    {"place": "b idle", "transition": "b takes r1", "kind": "out"},
+    {"place": "r1", "transition": "b takes r1", "kind": "out"},
+    {"place": "r2", "transition": "b takes r1", "kind": "out"}
+  ]
+}`
+
+func TestDeadlockTrace(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(resourcesNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := net.CheckLiveness(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := r.String()
+	if len(r.Deadlocks) != 1 || !strings.Contains(report, "deadlock a has r1=1 b has r2=1 after a takes r1 -> b takes r2") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+
+	if len(r.DeadTransitions) != 0 || len(r.NotLive) != 4 {
+		t.Errorf("dead %v, not live %v", r.DeadTransitions, r.NotLive)
+	}
+
+	if !strings.Contains(report, "siphon {r1, r2} can empty") {
+		t.Errorf("the siphon emptied by the deadlock is missing:\n%s", report)
+	}
+}
+
+func TestDeadTransitions(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(2, 1, 1.0, "smo group", &c)
+
+	r, err := net.CheckLiveness(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(r.DeadTransitions, ",") != "T0,T1" || !strings.Contains(r.String(), "after initial marking") {
+		t.Errorf("unexpected report:\n%s", r)
+	}
+
+	// the generator never stops, its state space is infinite
+	net = petri.CreateNetGenerator(100, 1, "", &c)
+	r, err = net.CheckLiveness(100)
+	if err != nil || r.Complete || r.Deadlocked() || len(r.DeadTransitions) != 0 {
+		t.Errorf("unexpected report for the generator: %v\n%s", err, r)
+	}
+}
+
+func TestSiphonLimit(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(`{
+  "name": "stores",
+  "places": [{"name": "a", "mark": 1}, {"name": "b"}, {"name": "c"}, {"name": "d"}, {"name": "e"}],
+  "transitions": [{"name": "move", "mean": 1}],
+  "arcs": [
+    {"place": "a", "transition": "move", "kind": "in"},
+    {"place": "b", "transition": "move", "kind": "out"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := net.CheckLiveness(0)
+	if err != nil || !r.SiphonsComplete || len(r.Siphons) != 4 {
+		t.Fatalf("unexpected report: %v\n%s", err, r)
+	}
+
+	// every place starts a search, three sets are not enough for five places
+	r, err = net.CheckLiveness(3)
+	if err != nil || r.SiphonsComplete || !strings.Contains(r.String(), "siphons and traps are incomplete") {
+		t.Errorf("siphon search cut off silently: %v\n%s", err, r)
+	}
+}
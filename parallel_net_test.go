@@ -18,7 +18,7 @@ func TestParallelObj(t *testing.T) {
 	//cond.Cond = sync.NewCond(&cond.Mux)
 
 	numObj := 2
-	model := GetModelSMOGroupForTestParallel(numObj, 10, &c, &gtime, &cond, channel)
+	model := GetModelSMOGroupForTestSerial(numObj, 10, &c, &gtime, &cond, channel)
 	timeModeling := 1000.0
 	model.GoRun(timeModeling)
 
@@ -0,0 +1,195 @@
+package parallel_testing
+
+import (
+	"bytes"
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+func TestLoadNetFiles(t *testing.T) {
+	var c petri.GlobalCounter
+
+	net, err := petri.LoadNet("models/smo_group.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := petri.CreateNetSMOGroup(2, 1, 1.0, "smo group", &c)
+	if len(net.Places) != len(expected.Places) || len(net.Transitions) != len(expected.Transitions) {
+		t.Fatalf("loaded net %d places %d transitions, want %d and %d",
+			len(net.Places), len(net.Transitions), len(expected.Places), len(expected.Transitions))
+	}
+
+	for i, tr := range net.Transitions {
+		if !equalInts(tr.InPlaces, expected.Transitions[i].InPlaces) || !equalInts(tr.OutPlaces, expected.Transitions[i].OutPlaces) {
+			t.Errorf("transition %s: in %v out %v, want in %v out %v", tr.Name,
+				tr.InPlaces, tr.OutPlaces, expected.Transitions[i].InPlaces, expected.Transitions[i].OutPlaces)
+		}
+	}
+
+	generator, err := petri.LoadNet("models/generator.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if generator.GetCurrentMark("P0") != 1 || generator.Transitions[0].AvgTimeServing != 10 {
+		t.Errorf("unexpected generator %+v", generator.Transitions[0])
+	}
+}
+
+func TestNetFileRoundTrip(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(3, 2, 1.5, "group", &c)
+
+	var buf bytes.Buffer
+	if err := petri.EncodeNetYAML(&buf, &net); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := petri.DecodeNetYAML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := petri.EncodeNetJSON(&buf, &loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := petri.DecodeNetJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(again.LinksIn) != len(net.LinksIn) || len(again.LinksOut) != len(net.LinksOut) {
+		t.Fatalf("links changed in round trip")
+	}
+
+	for i, p := range again.Places {
+		if p.Name != net.Places[i].Name || p.Mark != net.Places[i].Mark {
+			t.Errorf("place %d: %s %f, want %s %f", i, p.Name, p.Mark, net.Places[i].Name, net.Places[i].Mark)
+		}
+	}
+
+	for i, tr := range again.Transitions {
+		if tr.Distribution != "exp" || tr.AvgTimeServing != 1.5 || !equalInts(tr.InPlaces, net.Transitions[i].InPlaces) {
+			t.Errorf("transition %d changed in round trip: %+v", i, tr)
+		}
+	}
+}
+
+func TestNetFileErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown place":  `{"name": "n", "places": [{"name": "P0"}], "transitions": [{"name": "T0"}], "arcs": [{"place": "P1", "transition": "T0", "kind": "in"}]}`,
+		"duplicate":      `{"name": "n", "places": [{"name": "P0"}, {"name": "P0"}]}`,
+		"bad kind":       `{"name": "n", "places": [{"name": "P0"}], "transitions": [{"name": "T0"}], "arcs": [{"place": "P0", "transition": "T0", "kind": "both"}]}`,
+		"bad weight":     `{"name": "n", "places": [{"name": "P0"}], "transitions": [{"name": "T0"}], "arcs": [{"place": "P0", "transition": "T0", "kind": "in", "weight": -1}]}`,
+		"unknown distr.": `{"name": "n", "transitions": [{"name": "T0", "distribution": "expo"}]}`,
+	}
+
+	for name, body := range cases {
+		if _, err := petri.DecodeNetJSON(strings.NewReader(body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestPNMLRoundTrip(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(2, 3, 2.5, "group", &c)
+	net.LinksIn[1].SetInfo(true).SetQuantity(2)
+
+	var buf bytes.Buffer
+	if err := petri.EncodeNetPNML(&buf, &net); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := petri.DecodeNetPNML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got bytes.Buffer
+	petri.EncodeNetJSON(&want, &net)
+	petri.EncodeNetJSON(&got, &loaded)
+	if want.String() != got.String() {
+		t.Errorf("pnml round trip is lossy:\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+func TestPNMLForeign(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<pnml xmlns="http://www.pnml.org/version-2009/grammar/pnml">
+  <net id="n1" type="http://www.pnml.org/version-2009/grammar/ptnet">
+    <page id="top">
+      <place id="p1"><name><text>buffer</text></name><initialMarking><text>3</text></initialMarking></place>
+      <place id="p2"/>
+      <transition id="t1"><name><text>move</text></name></transition>
+      <arc id="a1" source="p1" target="t1"><inscription><text>2</text></inscription></arc>
+      <page id="sub">
+        <referencePlace id="rp2" ref="p2"/>
+        <arc id="a2" source="t1" target="rp2"/>
+      </page>
+    </page>
+  </net>
+</pnml>`
+
+	net, err := petri.DecodeNetPNML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if net.Name != "n1" || net.GetCurrentMark("buffer") != 3 || net.FindPlaceByName("p2") != 1 {
+		t.Fatalf("unexpected places %+v", net.Places)
+	}
+
+	move := net.Transitions[0]
+	if !equalInts(move.InPlaces, []int{0}) || !equalInts(move.CounterInPlaces, []int{2}) || !equalInts(move.OutPlaces, []int{1}) {
+		t.Errorf("unexpected arcs of %s: %+v", move.Name, move)
+	}
+}
+
+func TestPNMLNames(t *testing.T) {
+	pnml := func(body string) string {
+		return `<pnml><net id="n1"><page id="top">` + body + `</page></net></pnml>`
+	}
+
+	// places and transitions have own names
+	net, err := petri.DecodeNetPNML(strings.NewReader(pnml(`
+      <place id="p1"><name><text>P</text></name></place>
+      <transition id="t1"><name><text>P</text></name></transition>
+      <arc id="a1" source="p1" target="t1"/>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.Places[0].Name != "P" || net.Transitions[0].Name != "P" {
+		t.Errorf("place %s and transition %s, want both P", net.Places[0].Name, net.Transitions[0].Name)
+	}
+
+	bad := map[string]string{
+		"two nets":                `<pnml><net id="n1"><page id="a"/></net><net id="n2"><page id="b"/></net></pnml>`,
+		"arc between places":      pnml(`<place id="p1"/><place id="p2"/><transition id="t1"/><arc id="a1" source="p1" target="p2"/>`),
+		"arc between transitions": pnml(`<place id="p1"/><transition id="t1"/><transition id="t2"/><arc id="a1" source="t1" target="t2"/>`),
+		"arc to nothing":          pnml(`<place id="p1"/><transition id="t1"/><arc id="a1" source="t1" target="p9"/>`),
+	}
+	for what, doc := range bad {
+		if _, err := petri.DecodeNetPNML(strings.NewReader(doc)); err == nil {
+			t.Errorf("%s accepted", what)
+		}
+	}
+}
+
+func equalInts(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,213 @@
+package parallel_testing
+
+import (
+	"fmt"
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"strings"
+	"testing"
+)
+
+// tandemNet is a generator and a line of single servers in one net
+func tandemNet(stages int) petri.Net {
+	places := `{"name": "source", "mark": 1}, {"name": "q0"}`
+	transitions := `{"name": "arrive", "mean": 2}`
+	arcs := `{"place": "source", "transition": "arrive", "kind": "in"},
+    {"place": "source", "transition": "arrive", "kind": "out"},
+    {"place": "q0", "transition": "arrive", "kind": "out"}`
+	for i := 0; i < stages; i++ {
+		places += fmt.Sprintf(`, {"name": "s%d", "mark": 1}, {"name": "q%d"}`, i, i+1)
+		transitions += fmt.Sprintf(`, {"name": "T%d", "mean": 1.5}`, i)
+		arcs += fmt.Sprintf(`,
+    {"place": "q%d", "transition": "T%d", "kind": "in"},
+    {"place": "s%d", "transition": "T%d", "kind": "in"},
+    {"place": "s%d", "transition": "T%d", "kind": "out"},
+    {"place": "q%d", "transition": "T%d", "kind": "out"}`, i, i, i, i, i, i, i+1, i)
+	}
+
+	net, err := petri.DecodeNetJSON(strings.NewReader(fmt.Sprintf(`{
+  "name": "tandem",
+  "places": [%s],
+  "transitions": [%s],
+  "arcs": [%s]
+}`, places, transitions, arcs)))
+	if err != nil {
+		panic(err)
+	}
+
+	return net
+}
+
+func TestPartitionChain(t *testing.T) {
+	var gtime petri.GlobalTime
+	var c petri.GlobalCounter
+	net := tandemNet(8)
+	serial := (&petri.Model{}).Build([]*petri.Simulator{(&petri.Simulator{}).Build(net, &c, &gtime, nil, nil)}, &gtime)
+	serial.IsProtocolPrint = false
+	serial.GoRun(1000)
+
+	var pgtime petri.GlobalTime
+	var pc petri.GlobalCounter
+	part := (&petri.Partition{}).Build(tandemNet(8), 3)
+	model, err := part.Split(&pc, &pgtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.IsProtocolPrint = false
+	portRun(t, model, 1000)
+
+	if len(model.Objects) != 3 || len(part.Ports) != 2 {
+		t.Fatalf("%d objects and %d ports, want 3 and 2", len(model.Objects), len(part.Ports))
+	}
+
+	// a customer every 2 time units crosses each cut
+	if math.Abs(part.Rate-1) > 0.01 {
+		t.Errorf("expected %f messages per unit of time, want 1", part.Rate)
+	}
+	for k, load := range part.Loads {
+		if load > 1.2*4.5/3 {
+			t.Errorf("object %d fires %f times per unit of time, more than its share", k, load)
+		}
+	}
+
+	// the parts together run as the net did
+	for _, p := range serial.Objects[0].Places {
+		found := false
+		for _, obj := range model.Objects {
+			if i := obj.TNet.FindPlaceByName(p.Name); i >= 0 {
+				found = true
+				if obj.Places[i].Mark != p.Mark {
+					t.Errorf("place %s holds %f markers, serial run %f", p.Name, obj.Places[i].Mark, p.Mark)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("place %s is in no object", p.Name)
+		}
+	}
+}
+
+func TestPartitionHints(t *testing.T) {
+	var gtime petri.GlobalTime
+	var c petri.GlobalCounter
+	part := (&petri.Partition{Hints: map[string]int{"T7": 0, "q3": 1}}).Build(tandemNet(8), 2)
+	model, err := part.Split(&c, &gtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if model.Objects[0].TNet.FindTransitionByName("T7") < 0 || model.Objects[1].TNet.FindTransitionByName("T3") < 0 {
+		t.Errorf("hints not kept: %s and %s", model.Objects[0].Name, model.Objects[1].Name)
+	}
+
+	_, err = (&petri.Partition{Hints: map[string]int{"nothing": 0}}).Build(tandemNet(2), 2).Split(&c, &gtime)
+	if err == nil || !strings.Contains(err.Error(), "no such place or transition") {
+		t.Errorf("unknown hint gave %v", err)
+	}
+}
+
+func TestPartitionPilotLeavesNet(t *testing.T) {
+	net := tandemNet(3)
+	trace := &petri.Trace{Delays: []float64{1, 3, 2}, Cycle: true}
+	net.Transitions[0].SetDelay(trace)
+
+	streams := &petri.Streams{Seed: 3}
+	net.Transitions[1].SetDistribution("exp", 1.5).SetRandom(streams.Stream("T0"))
+
+	fired := 0
+	net.Transitions[2].AddActOutHook(func(*petri.Transition, []*petri.Place, float64) {
+		fired++
+	})
+
+	var gtime petri.GlobalTime
+	var c petri.GlobalCounter
+	if _, err := (&petri.Partition{}).Build(net, 2).Split(&c, &gtime); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pilot run neither called hooks nor drew from the trace or the streams of the net
+	if fired != 0 {
+		t.Errorf("pilot run called a hook %d times", fired)
+	}
+	if pos := trace.State(); pos != 0 {
+		t.Errorf("pilot run moved the trace to %v", pos)
+	}
+	if net.Transitions[1].Random.Float64() != streams.Stream("T0").Float64() {
+		t.Error("pilot run drew from the stream of a transition")
+	}
+}
+
+func TestPartitionCarriesTokens(t *testing.T) {
+	net := tandemNet(6)
+	net.Transitions[0].SetDistribution("const", 2)
+	for _, tr := range net.Transitions[1:] {
+		tr.SetDistribution("const", 1.5)
+	}
+
+	var gtime petri.GlobalTime
+	var c petri.GlobalCounter
+	model, err := (&petri.Partition{}).Build(net, 3).Split(&c, &gtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.IsProtocolPrint = false
+	model.SetColoured(true)
+	portRun(t, model, 200)
+
+	// customers keep the tokens made on arrival through all parts, nobody waits
+	var last *petri.Place
+	for _, obj := range model.Objects {
+		if i := obj.TNet.FindPlaceByName("q6"); i >= 0 {
+			last = obj.Places[i]
+		}
+	}
+	if last == nil || last.GetMark() < 90 || len(last.Tokens) != int(last.GetMark()) {
+		t.Fatalf("last queue %v", last)
+	}
+	seen := make(map[int64]bool)
+	for _, token := range last.Tokens {
+		if seen[token.ID] {
+			t.Errorf("token %d arrived twice", token.ID)
+		}
+		seen[token.ID] = true
+		if math.Abs(token.Arrived-token.Created-9) > 1e-9 {
+			t.Errorf("token %d made at %f arrived at %f, want 9 later", token.ID, token.Created, token.Arrived)
+		}
+	}
+}
+
+func TestPartitionOwnState(t *testing.T) {
+	net := tandemNet(3)
+	trace := &petri.Trace{Delays: []float64{1, 3, 2}, Cycle: true}
+	net.Transitions[0].SetDelay(trace)
+
+	var gtime petri.GlobalTime
+	var c petri.GlobalCounter
+	model, err := (&petri.Partition{Seed: 4}).Build(net, 2).Split(&c, &gtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.IsProtocolPrint = false
+	portRun(t, model, 100)
+
+	// the parts replay own copies of the trace and draw from own streams
+	if pos := trace.State(); pos != 0 {
+		t.Errorf("split run moved the trace of the net to %v", pos)
+	}
+	for _, obj := range model.Objects {
+		for _, tr := range obj.Transitions {
+			if tr.Random == nil || tr.Random == net.Transitions[net.FindTransitionByName(tr.Name)].Random {
+				t.Errorf("transition %s of %s has no own stream", tr.Name, obj.Name)
+			}
+		}
+	}
+
+	net.Transitions[1].SetGuard(func(places []*petri.Place) bool { return petri.MarkOf(places, "q3") < 5 })
+	_, err = (&petri.Partition{}).Build(net, 2).Split(&c, &gtime)
+	if err == nil || !strings.Contains(err.Error(), "guard") {
+		t.Errorf("net with a guard split with %v", err)
+	}
+	if _, err := (&petri.Partition{}).Build(net, 1).Split(&c, &gtime); err != nil {
+		t.Errorf("net with a guard in one part: %v", err)
+	}
+}
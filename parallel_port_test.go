@@ -0,0 +1,197 @@
+package parallel_testing
+
+import (
+	"context"
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"testing"
+	"time"
+)
+
+func portRun(t *testing.T, model *petri.Model, timeModeling float64) {
+	model.Gtime.ModTime = timeModeling
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := model.RunContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPortChain(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	serial := GetModelSMOGroupForTestSerial(4, 3, &c, &gtime, &cond, make(chan int))
+	serial.IsProtocolPrint = false
+	serial.SetSeed(5)
+	serial.GoRun(1000)
+
+	var pc petri.GlobalCounter
+	var pgtime petri.GlobalTime
+	parallel := GetModelSMOGroupForTestParallel(4, 3, &pc, &pgtime, &cond, make(chan int))
+	parallel.SetSeed(5)
+	portRun(t, parallel, 1000)
+
+	ports := 0
+	for _, obj := range parallel.Objects {
+		ports += len(obj.OutPorts)
+	}
+	if ports != 3 {
+		t.Fatalf("%d ports, want 3", ports)
+	}
+
+	for i, obj := range serial.Objects {
+		for k, tr := range obj.Transitions {
+			other := parallel.Objects[i].Transitions[k]
+			if tr.Buffer != other.Buffer || math.Abs(tr.Mean-other.Mean) > 1e-9 {
+				t.Errorf("object %s transition %s: buffer %d and mean %f, serial run %d and %f",
+					obj.Name, tr.Name, other.Buffer, other.Mean, tr.Buffer, tr.Mean)
+			}
+		}
+	}
+
+	last := len(serial.Objects) - 1
+	sink := len(serial.Objects[last].Places) - 1
+	if serial.Objects[last].Places[sink].Mark != parallel.Objects[last].Places[sink].Mark {
+		t.Errorf("%f markers served, serial run %f", parallel.Objects[last].Places[sink].Mark, serial.Objects[last].Places[sink].Mark)
+	}
+}
+
+func TestPortRing(t *testing.T) {
+	for _, delay := range []string{"", `, "distribution": "exp"`} {
+		var cgtime petri.GlobalTime
+		cons := linkedRing(delay, &cgtime)
+		cons.SetSeed(9)
+		conservativeRun(t, cons, 200)
+
+		var pgtime petri.GlobalTime
+		parallel := linkedRing(delay, &pgtime)
+		parallel.SetSeed(9)
+		portRun(t, parallel, 200)
+
+		jobs := 0.0
+		for i, obj := range parallel.Objects {
+			jobs += obj.Places[0].Mark + float64(obj.Transitions[0].Buffer)
+			other := cons.Objects[i]
+			if obj.Places[0].Mark != other.Places[0].Mark || obj.Transitions[0].Mean != other.Transitions[0].Mean {
+				t.Errorf("delay%s: %s holds %f jobs with mean %f, conservative run %f and %f", delay, obj.Name,
+					obj.Places[0].Mark, obj.Transitions[0].Mean, other.Places[0].Mark, other.Transitions[0].Mean)
+			}
+		}
+
+		if jobs != 6 {
+			t.Errorf("delay%s: %f jobs in the ring, want 6", delay, jobs)
+		}
+	}
+}
+
+func TestPortForkJoin(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	build := func(net petri.Net) *petri.Simulator {
+		return (&petri.Simulator{}).Build(net, &c, &gtime, &cond, make(chan int))
+	}
+
+	gen := build(petri.CreateNetGenerator(1000, 2, "exp", &c))
+	fork := build(petri.CreateNetFork(1000, 2, []float64{0.5, 0.5}))
+	groups := []*petri.Simulator{
+		build(petri.CreateNetSMOGroup(2, 1, 1, "left", &c)),
+		build(petri.CreateNetSMOGroup(2, 1, 1, "right", &c)),
+	}
+	merge := build(ringNet("merge", 0, ""))
+
+	links := []struct {
+		from       *petri.Simulator
+		transition string
+		to         *petri.Simulator
+		place      string
+	}{
+		{gen, "coming", fork, "P0"},
+		{fork, "choice route 1", groups[0], "P0"},
+		{fork, "choice route 2", groups[1], "P0"},
+		{groups[0], "T1", merge, "in"},
+		{groups[1], "T1", merge, "in"},
+	}
+	for _, l := range links {
+		if _, err := l.from.Connect(l.transition, l.to, l.place); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	model := (&petri.Model{}).Build([]*petri.Simulator{gen, fork, groups[0], groups[1], merge}, &gtime)
+	model.IsProtocolPrint = false
+	model.SetSeed(3)
+	portRun(t, model, 1000)
+
+	// every marker a branch took is still in its group or went on to the merge
+	served := 0.0
+	for i, g := range groups {
+		routed := fork.Places[i+1].Mark
+		if routed == 0 {
+			t.Errorf("no markers took branch %s", g.Name)
+		}
+
+		inside := g.Places[0].Mark + g.Places[2].Mark + g.Places[4].Mark
+		for _, tr := range g.Transitions {
+			inside += float64(tr.Buffer)
+		}
+		if inside != routed {
+			t.Errorf("%f markers routed to %s, %f arrived", routed, g.Name, inside)
+		}
+		served += g.Places[4].Mark
+	}
+
+	merged := merge.Places[0].Mark + merge.Places[1].Mark + float64(merge.Transitions[0].Buffer)
+	if merged != served {
+		t.Errorf("%f markers served by the branches, %f came to the merge", served, merged)
+	}
+}
+
+func TestPortMailbox(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	serial := GetModelSMOGroupForTestSerial(4, 3, &c, &gtime, &cond, make(chan int))
+	serial.IsProtocolPrint = false
+	serial.SetSeed(7)
+	serial.GoRun(1000)
+
+	var pc petri.GlobalCounter
+	var pgtime petri.GlobalTime
+	parallel := GetModelSMOGroupForTestSerial(4, 3, &pc, &pgtime, &cond, make(chan int))
+	parallel.SetSeed(7)
+	for _, obj := range parallel.Objects {
+		obj.Limit = 1
+	}
+	if err := parallel.ConnectShared(); err != nil {
+		t.Fatal(err)
+	}
+	portRun(t, parallel, 1000)
+
+	// senders waited for full mailboxes, nothing got lost or reordered
+	var waits int64
+	for _, obj := range parallel.Objects {
+		for _, p := range obj.OutPorts {
+			waits += p.Mailbox.Waits()
+			if p.Mailbox.Cap() != 1 || p.Mailbox.Len() != 0 {
+				t.Errorf("port %s -> %s: %d of %d messages left", obj.Name, p.To.Name, p.Mailbox.Len(), p.Mailbox.Cap())
+			}
+		}
+	}
+	if waits == 0 {
+		t.Error("no sender waited for a mailbox holding one message")
+	}
+
+	for i, obj := range serial.Objects {
+		for k, tr := range obj.Transitions {
+			if other := parallel.Objects[i].Transitions[k]; tr.Buffer != other.Buffer || math.Abs(tr.Mean-other.Mean) > 1e-9 {
+				t.Errorf("object %s transition %s: buffer %d and mean %f, serial run %d and %f",
+					obj.Name, tr.Name, other.Buffer, other.Mean, tr.Buffer, tr.Mean)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"testing"
+)
+
+func runSeeded(seed int64) []float64 {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestSerial(3, 3, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(seed)
+	model.GoRun(1000)
+
+	return statistics(model)
+}
+
+// runSeededParallel runs the chain of runSeeded with a goroutine per object
+func runSeededParallel(t *testing.T, seed int64) []float64 {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestParallel(3, 3, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(seed)
+	portRun(t, model, 1000)
+
+	return statistics(model)
+}
+
+func statistics(model *petri.Model) []float64 {
+	var stats []float64
+	for _, obj := range model.Objects {
+		for _, p := range obj.Places {
+			stats = append(stats, p.Mean, p.Mark)
+		}
+
+		for _, t := range obj.Transitions {
+			stats = append(stats, t.Mean)
+		}
+	}
+
+	return stats
+}
+
+func TestSeededRunsAreReproducible(t *testing.T) {
+	first := runSeeded(42)
+	second := runSeeded(42)
+	other := runSeeded(43)
+
+	same := true
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("statistic %d differs between runs with the same seed: %v != %v", i, first[i], second[i])
+		}
+
+		if first[i] != other[i] {
+			same = false
+		}
+	}
+
+	if same {
+		t.Errorf("runs with different seeds gave the same statistics")
+	}
+}
+
+func TestSeededParallelRunsAreReproducible(t *testing.T) {
+	// objects exchange markers through ports only, so the schedule of the goroutines doesn't matter
+	first := runSeededParallel(t, 42)
+	second := runSeededParallel(t, 42)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("statistic %d differs between parallel runs with the same seed: %v != %v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestSeededRunModesAgree(t *testing.T) {
+	// GoRun and a goroutine per object draw the same numbers from the same streams
+	serial := runSeeded(42)
+	parallel := runSeededParallel(t, 42)
+	for i := range serial {
+		if math.Abs(serial[i]-parallel[i]) > 1e-9 {
+			t.Errorf("statistic %d is %v in the serial run, %v with a goroutine per object", i, serial[i], parallel[i])
+		}
+	}
+}
+
+func TestStreamsAreIndependent(t *testing.T) {
+	st := &petri.Streams{Seed: 7}
+
+	a := st.Stream("object", 1, "transition", "T0").Float64()
+	if b := st.Stream("object", 1, "transition", "T0").Float64(); a != b {
+		t.Errorf("same keys gave different streams")
+	}
+
+	if b := st.Stream("object", 1, "transition", "T1").Float64(); a == b {
+		t.Errorf("different keys gave the same stream")
+	}
+
+	if b := (&petri.Streams{Seed: 8}).Stream("object", 1, "transition", "T0").Float64(); a == b {
+		t.Errorf("different seeds gave the same stream")
+	}
+}
+
+func TestSamplersFromStreams(t *testing.T) {
+	st := &petri.Streams{Seed: 5}
+	if petri.ExpFrom(st.Stream("exp"), 2) != petri.ExpFrom(st.Stream("exp"), 2) {
+		t.Error("same stream gave different exponential delays")
+	}
+
+	// the old samplers draw from math/rand
+	if v := petri.Exp(2); v <= 0 {
+		t.Errorf("exponential delay %f", v)
+	}
+	if v := petri.Uniform(1, 3); v < 1 || v > 3 {
+		t.Errorf("uniform delay %f out of [1, 3]", v)
+	}
+	if v := petri.Normal(5, 0); v != 5 {
+		t.Errorf("normal delay without deviation %f", v)
+	}
+	if v, err := petri.Empiric([]float64{1, 2}, []float64{0, 1}); err != nil || v < 1 || v > 2 {
+		t.Errorf("empiric delay %f: %v", v, err)
+	}
+}
@@ -0,0 +1,60 @@
+package parallel_testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+func TestReachabilityGraph(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(2, 1, 1.0, "smo group", &c)
+	net.Places[0].SetMark(2)
+
+	g, err := net.ReachabilityGraph(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two customers spread over the queues P0, P2 and the exit P4
+	if len(g.States) != 6 || len(g.Edges) != 6 {
+		t.Errorf("got %d states and %d edges, want 6 and 6", len(g.States), len(g.Edges))
+	}
+
+	dead := g.Dead()
+	if len(dead) != 1 || g.States[dead[0]].Marking.Format(g.Places) != "P1=1 P3=1 P4=2" {
+		t.Fatalf("dead states %v", dead)
+	}
+
+	if len(g.Successors(0)) != 1 {
+		t.Errorf("initial state has %d successors, want 1", len(g.Successors(0)))
+	}
+
+	if path := g.Path(dead[0]); len(path) != 4 {
+		t.Errorf("shortest path %v has not 4 firings", path)
+	}
+
+	var dot, js bytes.Buffer
+	petri.WriteReachabilityDot(&dot, g)
+	if !strings.Contains(dot.String(), "color=red") || strings.Count(dot.String(), "->") != 6 {
+		t.Errorf("unexpected dot:\n%s", dot.String())
+	}
+
+	petri.EncodeReachabilityJSON(&js, g)
+	var decoded petri.ReachabilityGraph
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded.States) != 6 || !decoded.Complete {
+		t.Errorf("json doesn't decode back: %v\n%s", err, js.String())
+	}
+}
+
+func TestReachabilityLimit(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetGenerator(100, 1, "", &c)
+
+	g, err := net.ReachabilityGraph(50)
+	if err == nil || g.Complete || len(g.States) != 50 {
+		t.Errorf("unbounded net explored to %d states, error %v", len(g.States), err)
+	}
+}
@@ -0,0 +1,70 @@
+package parallel_testing
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+func TestRenderNet(t *testing.T) {
+	var c petri.GlobalCounter
+	net := petri.CreateNetSMOGroup(2, 1, 1.0, "group", &c)
+	net.LinksIn[1].SetInfo(true).SetQuantity(3)
+
+	var dot bytes.Buffer
+	if err := petri.WriteNetDot(&dot, &net); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`digraph "group"`, `"p1" [shape=circle, label="P1\n1"]`, `xlabel="T0\nbuffer: 0"`, `"p1" -> "t0" [label="3", style=dashed]`} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("dot output has no %s:\n%s", want, dot.String())
+		}
+	}
+
+	var svg bytes.Buffer
+	if err := petri.WriteNetSVG(&svg, &net); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := xml.Unmarshal(svg.Bytes(), new(interface{})); err != nil {
+		t.Errorf("svg is not well formed: %v", err)
+	}
+}
+
+func TestRenderModel(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestSerial(3, 2, &c, &gtime, &cond, make(chan int))
+
+	var dot bytes.Buffer
+	if err := petri.WriteModelDot(&dot, model); err != nil {
+		t.Fatal(err)
+	}
+
+	out := dot.String()
+	if strings.Count(out, "subgraph cluster_") != 3 {
+		t.Errorf("expected a cluster per object:\n%s", out)
+	}
+
+	if strings.Count(out, "doublecircle") != 2 {
+		t.Errorf("expected two shared places:\n%s", out)
+	}
+
+	if strings.Count(out, "color=blue") != 2 {
+		t.Errorf("expected two links between objects:\n%s", out)
+	}
+
+	var svg bytes.Buffer
+	if err := petri.WriteModelSVG(&svg, model); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(svg.String(), "<svg") {
+		t.Errorf("no svg rendered")
+	}
+}
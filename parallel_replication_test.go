@@ -0,0 +1,106 @@
+package parallel_testing
+
+import (
+	"context"
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestTallyHalfWidth(t *testing.T) {
+	var tally petri.Tally
+	for i := 1; i <= 10; i++ {
+		tally.Add(float64(i))
+	}
+
+	// t quantile 0.975 with 9 degrees of freedom is 2.262157
+	want := 2.262157 * tally.Std() / math.Sqrt(10)
+	if hw := tally.HalfWidth(0.95); math.Abs(hw-want) > 1e-5 {
+		t.Errorf("half width %f, want %f", hw, want)
+	}
+
+	// a large mean with a small spread doesn't cancel out
+	var large petri.Tally
+	for i := 1; i <= 10; i++ {
+		large.Add(1e9 + float64(i))
+	}
+	if math.Abs(large.Std()-tally.Std()) > 1e-6 {
+		t.Errorf("deviation %f around 1e9, want %f", large.Std(), tally.Std())
+	}
+}
+
+func TestReplications(t *testing.T) {
+	var mu sync.Mutex
+	running, most, built := 0, 0, 0
+
+	factory := func(int) *petri.Model {
+		var c petri.GlobalCounter
+		var gtime petri.GlobalTime
+		var cond petri.GlobalLocker
+
+		mu.Lock()
+		built++
+		mu.Unlock()
+
+		model := GetModelSMOGroupForTestSerial(3, 2, &c, &gtime, &cond, make(chan int))
+		model.IsProtocolPrint = false
+		return model
+	}
+
+	simulate := func(ctx context.Context, m *petri.Model) error {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		return m.GoRunContext(ctx, 500)
+	}
+
+	var runs []*petri.Replications
+	for _, workers := range []int{1, 3} {
+		r := (&petri.Replications{Workers: workers, Seed: 11, Simulate: simulate}).Build(factory, 8, 500)
+		if err := r.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, r)
+	}
+
+	if built != 16 || most > 3 {
+		t.Errorf("%d models built, %d replications at once", built, most)
+	}
+
+	// the pool doesn't change the results
+	for i, p := range runs[0].Places {
+		if other := runs[1].Places[i]; p.Mean != other.Mean || p.ObservedMax != other.ObservedMax {
+			t.Errorf("place %s of %s: %v with one worker, %v with three", p.Place, p.Object, p.Mean, other.Mean)
+		}
+	}
+
+	free := runs[1].Place("group_0", "P1")
+	if free == nil {
+		t.Fatal("no estimates of free channels P1 of group_0")
+	}
+	if free.Mean.N != 8 || free.Mean.Std == 0 {
+		t.Errorf("free channels over replications %v, want 8 different ones", free.Mean)
+	}
+	if free.Mean.Low() > free.Mean.Mean || free.ObservedMax.Mean < free.Mean.Mean || free.ObservedMin.Mean > free.Mean.Mean {
+		t.Errorf("free channels mean %v, max %v, min %v", free.Mean, free.ObservedMax, free.ObservedMin)
+	}
+
+	// the generator and the group share a place, both keep own estimates of it
+	if n := runs[1].Place("group_0", "P0").Mean.N; n != 8 {
+		t.Errorf("%d replications of queue P0 of group_0, want 8", n)
+	}
+
+	if tr := runs[1].Transition("group_0", "T0"); tr == nil || tr.Mean.Mean <= 0 || math.IsInf(tr.Mean.HalfWidth, 0) {
+		t.Errorf("transition T0 of group_0: %v", tr)
+	}
+}
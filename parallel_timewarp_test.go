@@ -0,0 +1,204 @@
+package parallel_testing
+
+import (
+	"context"
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func timeWarpRun(t testing.TB, warp *petri.TimeWarp, model *petri.Model, timeModeling float64) *petri.TimeWarp {
+	warp.Build(model.Objects, timeModeling)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := warp.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return warp
+}
+
+func TestTimeWarpChain(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	serial := GetModelSMOGroupForTestSerial(4, 3, &c, &gtime, &cond, make(chan int))
+	serial.IsProtocolPrint = false
+	serial.SetSeed(5)
+	serial.GoRun(1000)
+
+	for _, window := range []float64{0, 20} {
+		var pc petri.GlobalCounter
+		var pgtime petri.GlobalTime
+		parallel := GetModelSMOGroupForTestParallel(4, 3, &pc, &pgtime, &cond, make(chan int))
+		parallel.SetSeed(5)
+		warp := timeWarpRun(t, &petri.TimeWarp{GVTInterval: 10, Window: window}, parallel, 1000)
+
+		// rolled back streams draw the same numbers again, so the run is the serial one
+		for i, obj := range serial.Objects {
+			for k, tr := range obj.Transitions {
+				other := parallel.Objects[i].Transitions[k]
+				if tr.Buffer != other.Buffer || math.Abs(tr.Mean-other.Mean) > 1e-9 {
+					t.Errorf("window %f: object %s transition %s: buffer %d and mean %f, serial run %d and %f",
+						window, obj.Name, tr.Name, other.Buffer, other.Mean, tr.Buffer, tr.Mean)
+				}
+			}
+
+			for k, p := range obj.Places {
+				other := parallel.Objects[i].Places[k]
+				if p.Mark != other.Mark || math.Abs(p.Mean-other.Mean) > 1e-9 {
+					t.Errorf("window %f: object %s place %s: mark %f and mean %f, serial run %f and %f",
+						window, obj.Name, p.Name, other.Mark, other.Mean, p.Mark, p.Mean)
+				}
+			}
+		}
+
+		if warp.GVT() <= 1000 {
+			t.Errorf("window %f: run ended at GVT %f", window, warp.GVT())
+		}
+
+		if warp.MaxSaved >= int(warp.Events) {
+			t.Errorf("window %f: %d of %d states kept at once, fossil collection freed none", window, warp.MaxSaved, warp.Events)
+		}
+	}
+}
+
+func TestTimeWarpRing(t *testing.T) {
+	for _, delay := range []string{"", `, "distribution": "exp"`} {
+		var gtime petri.GlobalTime
+		serial := ringModel(delay, &gtime)
+		serial.SetSeed(9)
+		serial.GoRun(200)
+
+		var cgtime petri.GlobalTime
+		cons := linkedRing(delay, &cgtime)
+		cons.SetSeed(9)
+		conservativeRun(t, cons, 200)
+
+		var pgtime petri.GlobalTime
+		parallel := linkedRing(delay, &pgtime)
+		parallel.SetSeed(9)
+		warp := timeWarpRun(t, &petri.TimeWarp{GVTInterval: 10}, parallel, 200)
+
+		// objects of both engines keep statistics in own time, the serial run in model time
+		jobs := 0.0
+		for i, obj := range parallel.Objects {
+			jobs += obj.Places[0].Mark + float64(obj.Transitions[0].Buffer)
+			if obj.Places[0].Mark != serial.Objects[i].Places[0].Mark {
+				t.Errorf("delay%s: %s holds %f jobs, serial run %f", delay, obj.Name, obj.Places[0].Mark, serial.Objects[i].Places[0].Mark)
+			}
+
+			if mean := cons.Objects[i].Transitions[0].Mean; obj.Transitions[0].Mean != mean {
+				t.Errorf("delay%s: %s mean %f, conservative run %f", delay, obj.Name, obj.Transitions[0].Mean, mean)
+			}
+		}
+
+		if jobs != 6 {
+			t.Errorf("delay%s: %f jobs in the ring, want 6", delay, jobs)
+		}
+
+		// without lookahead the objects run ahead of each other
+		if delay != "" && warp.Rollbacks == 0 {
+			t.Errorf("delay%s: ring with exponential delays ran without rollbacks", delay)
+		}
+	}
+}
+
+func TestTimeWarpTrace(t *testing.T) {
+	// every object replays its own trace, rollbacks take the traces back as well
+	traced := func(gtime *petri.GlobalTime) *petri.Model {
+		model := ringModel("", gtime)
+		r := rand.New(rand.NewSource(4))
+		for _, obj := range model.Objects {
+			delays := make([]float64, 50)
+			for i := range delays {
+				delays[i] = r.ExpFloat64()
+			}
+			obj.Transitions[0].SetDelay(&petri.Trace{Delays: delays, Cycle: true})
+		}
+
+		// the lookahead of the ports comes from the traces
+		if err := model.ConnectShared(); err != nil {
+			t.Fatal(err)
+		}
+		return model
+	}
+
+	var cgtime petri.GlobalTime
+	cons := traced(&cgtime)
+	conservativeRun(t, cons, 200)
+
+	var pgtime petri.GlobalTime
+	parallel := traced(&pgtime)
+	warp := timeWarpRun(t, &petri.TimeWarp{GVTInterval: 10}, parallel, 200)
+	if warp.Rollbacks == 0 {
+		t.Errorf("ring replaying traces ran without rollbacks")
+	}
+
+	for i, obj := range parallel.Objects {
+		other := cons.Objects[i]
+		if obj.Places[0].Mark != other.Places[0].Mark || obj.Transitions[0].Mean != other.Transitions[0].Mean {
+			t.Errorf("%s holds %f jobs with mean %f, conservative run %f and %f", obj.Name,
+				obj.Places[0].Mark, obj.Transitions[0].Mean, other.Places[0].Mark, other.Transitions[0].Mean)
+		}
+	}
+}
+
+func TestTimeWarpCancel(t *testing.T) {
+	var gtime petri.GlobalTime
+	model := linkedRing("", &gtime)
+	warp := (&petri.TimeWarp{}).Build(model.Objects, 1e9)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := warp.Run(ctx)
+	if err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+		t.Errorf("run past the deadline returned %v", err)
+	}
+}
+
+// BenchmarkSMOChain compares the engines on the chain of TestParallel
+func BenchmarkSMOChain(b *testing.B) {
+	const timeModeling = 2000
+	chain := func(build func(int, int, *petri.GlobalCounter, *petri.GlobalTime, *petri.GlobalLocker, chan int) *petri.Model) *petri.Model {
+		var c petri.GlobalCounter
+		var gtime petri.GlobalTime
+		var cond petri.GlobalLocker
+
+		model := build(6, 10, &c, &gtime, &cond, make(chan int))
+		model.IsProtocolPrint = false
+		model.SetSeed(1)
+		return model
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			chain(GetModelSMOGroupForTestSerial).GoRun(timeModeling)
+		}
+	})
+
+	b.Run("conservative", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			model := chain(GetModelSMOGroupForTestParallel)
+			cons := (&petri.Conservative{}).Build(model.Objects, timeModeling)
+			if err := cons.Run(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("timewarp", func(b *testing.B) {
+		var rolledBack, events int64
+		for i := 0; i < b.N; i++ {
+			warp := timeWarpRun(b, &petri.TimeWarp{}, chain(GetModelSMOGroupForTestParallel), timeModeling)
+			rolledBack += warp.RolledBack
+			events += warp.Events
+		}
+		b.ReportMetric(float64(rolledBack)/float64(events), "rolledback/event")
+	})
+}
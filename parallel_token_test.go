@@ -0,0 +1,161 @@
+package parallel_testing
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/enabokov/parallel-testing/petri"
+	"math"
+	"strings"
+	"testing"
+)
+
+const classNet = `{
+  "name": "classes",
+  "places": [
+    {"name": "source", "mark": 1, "coloured": true},
+    {"name": "queue", "coloured": true},
+    {"name": "done vip", "coloured": true},
+    {"name": "done regular", "coloured": true}
+  ],
+  "transitions": [
+    {"name": "arrive", "mean": 1},
+    {"name": "serve vip", "mean": 0.5},
+    {"name": "serve regular", "mean": 0.5}
+  ],
+  "arcs": [
+    {"place": "source", "transition": "arrive", "kind": "in"},
+    {"place": "source", "transition": "arrive", "kind": "out"},
+    {"place": "queue", "transition": "arrive", "kind": "out", "class": "regular"},
+    {"place": "queue", "transition": "serve vip", "kind": "in", "class": "vip"},
+    {"place": "done vip", "transition": "serve vip", "kind": "out"},
+    {"place": "queue", "transition": "serve regular", "kind": "in", "class": "regular"},
+    {"place": "done regular", "transition": "serve regular", "kind": "out"}
+  ]
+}`
+
+func checkTokens(t *testing.T, places []*petri.Place) {
+	for _, p := range places {
+		if p.IsColoured() && len(p.Tokens) != int(p.Mark) {
+			t.Errorf("place %s has mark %f and %d tokens", p.Name, p.Mark, len(p.Tokens))
+		}
+	}
+}
+
+func TestColouredTokensAlongChain(t *testing.T) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	model := GetModelSMOGroupForTestSerial(3, 2, &c, &gtime, &cond, make(chan int))
+	model.IsProtocolPrint = false
+	model.SetSeed(1).SetColoured(true)
+	model.GoRun(1000)
+
+	for _, obj := range model.Objects {
+		checkTokens(t, obj.Places)
+	}
+
+	last := model.Objects[len(model.Objects)-1]
+	sink := last.Places[len(last.Places)-1]
+	if sink.Mark == 0 || sink.Age.Count != int(sink.Mark) {
+		t.Fatalf("%f tokens left the chain, %d latencies measured", sink.Mark, sink.Age.Count)
+	}
+
+	// the generator creates a token every 10 time units, four exponential servers come after it
+	if sink.Age.Mean() < 1 || sink.Age.Mean() > 20 {
+		t.Errorf("mean latency %f is implausible", sink.Age.Mean())
+	}
+
+	for _, token := range sink.Tokens {
+		if math.Mod(token.Created, 10) != 0 {
+			t.Errorf("token %d was created at %f, not by the generator", token.ID, token.Created)
+		}
+	}
+
+	queue := model.Objects[1].Places[0]
+	if queue.Sojourn.Count == 0 || queue.Sojourn.Min < 0 {
+		t.Errorf("waiting times in the first queue: %+v", queue.Sojourn)
+	}
+}
+
+func TestTokenIDsRepeat(t *testing.T) {
+	run := func() []int64 {
+		var c petri.GlobalCounter
+		var gtime petri.GlobalTime
+		var cond petri.GlobalLocker
+
+		model := GetModelSMOGroupForTestSerial(3, 2, &c, &gtime, &cond, make(chan int))
+		model.IsProtocolPrint = false
+		model.SetColoured(true).SetSeed(1)
+		model.GoRun(200)
+
+		var ids []int64
+		seen := make(map[int64]bool)
+		for _, obj := range model.Objects {
+			for _, p := range obj.Places {
+				for _, token := range p.Tokens {
+					if seen[token.ID] {
+						t.Errorf("token %d in place %s is there twice", token.ID, p.Name)
+					}
+					seen[token.ID] = true
+					ids = append(ids, token.ID)
+				}
+			}
+		}
+
+		return ids
+	}
+
+	// the second model numbers its tokens from the start like the first one
+	first, second := run(), run()
+	if len(first) == 0 || fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("token ids %v, then %v", first, second)
+	}
+}
+
+func TestTokenClasses(t *testing.T) {
+	net, err := petri.DecodeNetJSON(strings.NewReader(classNet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every third customer is a vip
+	arrive := net.Transitions[net.FindTransitionByName("arrive")]
+	n := 0
+	arrive.AddActOutHook(func(tr *petri.Transition, places []*petri.Place, currentTime float64) {
+		for _, token := range tr.Fired {
+			if token.Class == "regular" {
+				if n%3 == 0 {
+					token.Class = "vip"
+				}
+				n++
+			}
+		}
+	})
+
+	runNet(net, 30)
+	checkTokens(t, net.Places)
+
+	vip := net.Places[net.FindPlaceByName("done vip")]
+	regular := net.Places[net.FindPlaceByName("done regular")]
+	if vip.Mark != 10 || regular.Mark+float64(net.GetCurrentBuffer("serve regular")) != 20 {
+		t.Errorf("served %f vip and %f regular customers of %d", vip.Mark, regular.Mark, n)
+	}
+
+	for _, token := range vip.Tokens {
+		if token.Class != "vip" {
+			t.Errorf("token %d of class %s served as vip", token.ID, token.Class)
+		}
+	}
+
+	var pnml bytes.Buffer
+	petri.EncodeNetPNML(&pnml, &net)
+	loaded, err := petri.DecodeNetPNML(&pnml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Places[1].IsColoured() || loaded.LinksIn[1].GetClass() != "vip" {
+		t.Errorf("classes are lost in pnml")
+	}
+}
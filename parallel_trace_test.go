@@ -0,0 +1,112 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runGenerator(net petri.Net, timeModeling float64) float64 {
+	runNet(net, timeModeling)
+	return net.GetCurrentMark("P1")
+}
+
+func runNet(net petri.Net, timeModeling float64) {
+	var c petri.GlobalCounter
+	var gtime petri.GlobalTime
+	var cond petri.GlobalLocker
+
+	sim := (&petri.Simulator{}).Build(net, &c, &gtime, &cond, make(chan int))
+	model := (&petri.Model{}).Build([]*petri.Simulator{sim}, &gtime)
+	model.IsProtocolPrint = false
+	model.GoRun(timeModeling)
+}
+
+func TestTraceReplay(t *testing.T) {
+	trace, err := petri.ReadTrace(strings.NewReader("delay\n1\n2\n3\n"), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c petri.GlobalCounter
+	net := petri.CreateNetGeneratorFromTrace(100, trace, &c)
+	if n := runGenerator(net, 100); n != 3 {
+		t.Errorf("replayed %f arrivals once, want 3", n)
+	}
+
+	// the used up trace leaves the transition idle, not busy until the end
+	if coming := net.Transitions[0]; coming.Buffer != 0 || coming.Mean > 0.1 {
+		t.Errorf("transition of the used up trace has %d busy channels, busy %f of the time", coming.Buffer, coming.Mean)
+	}
+
+	trace.Rewind()
+	trace.Cycle = true
+	if n := runGenerator(petri.CreateNetGeneratorFromTrace(100, trace, &c), 100); n != 50 {
+		t.Errorf("replayed %f arrivals cycling, want 50", n)
+	}
+}
+
+func TestTraceFromNetFile(t *testing.T) {
+	net, err := petri.LoadNet("models/trace_generator.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// arrivals at 1, 3, 6 repeat every 6 time units
+	if n := runGenerator(net, 60); n != 30 {
+		t.Errorf("replayed %f arrivals, want 30", n)
+	}
+}
+
+func TestTraceErrors(t *testing.T) {
+	if _, err := petri.ReadTrace(strings.NewReader("1\n3\n2\n"), 0, true); err == nil {
+		t.Errorf("timestamps going back accepted")
+	}
+
+	if _, err := petri.ReadTrace(strings.NewReader("1,2\n3\n"), 1, false); err == nil {
+		t.Errorf("missing column accepted")
+	}
+
+	// a first row with numbers is a record, not a header
+	if _, err := petri.ReadTrace(strings.NewReader("1,x\n2,3\n"), 1, false); err == nil {
+		t.Errorf("bad first record skipped as a header")
+	}
+	if trace, err := petri.ReadTrace(strings.NewReader("time,delay\n2,3\n"), 1, false); err != nil || len(trace.Delays) != 1 {
+		t.Errorf("header not skipped: %v", err)
+	}
+}
+
+func TestTraceSaveNet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	net, err := petri.LoadNet("models/trace_generator.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the trace stays where it is, saved nets point to it from their own directories
+	for _, path := range []string{filepath.Join(dir, "out", "generator.yaml"), filepath.Join(dir, "generator.json")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := petri.SaveNet(path, &net); err != nil {
+			t.Fatal(err)
+		}
+
+		net, err = petri.LoadNet(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := runGenerator(net, 60); n != 30 {
+			t.Errorf("%s: replayed %f arrivals, want 30", path, n)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+func TestValidateGeneratedNets(t *testing.T) {
+	var c petri.GlobalCounter
+
+	nets := []petri.Net{
+		petri.CreateNetGenerator(1000, 10, "norm", &c),
+		petri.CreateNetSMOGroup(10, 1, 1.0, "group_0", &c),
+		petri.CreateNetSMOGroup(3, 2, 1.0, "group_1", &c),
+		petri.CreateNetFork(1000, 3, []float64{0.2, 0.3, 0.5}),
+	}
+
+	for _, n := range nets {
+		if ps := n.Validate(); len(ps) > 0 {
+			t.Errorf("net %s has problems:\n%s", n.Name, ps.String())
+		}
+	}
+}
+
+func TestValidateBrokenNet(t *testing.T) {
+	var c petri.GlobalCounter
+
+	places := []*petri.Place{
+		(&petri.Place{}).Build("P0", 1, &c),
+		(&petri.Place{}).Build("P0", 0, &c),
+	}
+
+	transitions := []*petri.Transition{
+		(&petri.Transition{}).Build("T0", 1, 0.3, &c),
+		(&petri.Transition{}).Build("T1", 1, 0.3, &c),
+		(&petri.Transition{}).Build("T2", 1, 1, &c),
+	}
+
+	linksIn := []*petri.Linker{
+		(&petri.Linker{}).Build(places[0], transitions[0], 1, false, &c, `i`),
+		(&petri.Linker{}).Build(places[0], transitions[1], 0, false, &c, `i`),
+	}
+
+	linksOut := []*petri.Linker{
+		(&petri.Linker{}).Build(places[1], transitions[0], 1, false, &c, `o`),
+	}
+	linksOut[0].SetCounterPlaces(5)
+
+	_, err := (&petri.Net{}).BuildChecked("broken", places, transitions, linksIn, linksOut)
+	verr, ok := err.(*petri.ValidationError)
+	if !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	expected := map[string]bool{
+		"place P0: duplicate place name":                               false,
+		"link o#0: place index 5 out of range [0, 2)":                  false,
+		"link i#1: weight 0 is not positive":                           false,
+		"transition T2: no input places, transition is always enabled": false,
+	}
+
+	for _, p := range verr.Problems {
+		if p.Severity != petri.SeverityError {
+			t.Errorf("warning %s among errors", p)
+		}
+
+		for suffix := range expected {
+			if strings.HasSuffix(p.String(), suffix) {
+				expected[suffix] = true
+			}
+		}
+	}
+
+	for suffix, found := range expected {
+		if !found {
+			t.Errorf("no problem %q in:\n%s", suffix, verr.Problems.String())
+		}
+	}
+
+	var sums bool
+	for _, p := range (&petri.Net{Places: places, Transitions: transitions, LinksIn: linksIn, LinksOut: linksOut}).Validate().Warnings() {
+		if p.Place == "P0" && p.Transition == "" {
+			sums = true
+		}
+	}
+
+	if !sums {
+		t.Errorf("expected warning about probabilities of T0 and T1")
+	}
+}
@@ -0,0 +1,86 @@
+package parallel_testing
+
+import (
+	"github.com/enabokov/parallel-testing/petri"
+	"strings"
+	"testing"
+)
+
+// backlogModel is a server starting with 100 waiting customers, the queue is gone by time 600
+func backlogModel(gtime *petri.GlobalTime) *petri.Model {
+	var c petri.GlobalCounter
+	net, err := petri.DecodeNetJSON(strings.NewReader(`{
+  "name": "backlog",
+  "places": [{"name": "source", "mark": 1}, {"name": "queue", "mark": 100}, {"name": "server", "mark": 1}, {"name": "done"}],
+  "transitions": [{"name": "arrive", "mean": 2}, {"name": "serve", "mean": 1.5}],
+  "arcs": [
+    {"place": "source", "transition": "arrive", "kind": "in"},
+    {"place": "source", "transition": "arrive", "kind": "out"},
+    {"place": "queue", "transition": "arrive", "kind": "out"},
+    {"place": "queue", "transition": "serve", "kind": "in"},
+    {"place": "server", "transition": "serve", "kind": "in"},
+    {"place": "server", "transition": "serve", "kind": "out"},
+    {"place": "done", "transition": "serve", "kind": "out"}
+  ]
+}`))
+	if err != nil {
+		panic(err)
+	}
+
+	model := (&petri.Model{}).Build([]*petri.Simulator{(&petri.Simulator{}).Build(net, &c, gtime, nil, nil)}, gtime)
+	model.IsProtocolPrint = false
+	return model
+}
+
+func TestWarmUp(t *testing.T) {
+	var gtime petri.GlobalTime
+	biased := backlogModel(&gtime)
+	biased.GoRun(3000)
+
+	var wgtime petri.GlobalTime
+	steady := backlogModel(&wgtime).SetWarmUp(1000)
+	steady.GoRun(3000)
+
+	queue := biased.Objects[0].Places[1]
+	if queue.Mean < 5 || queue.ObservedMax < 100 {
+		t.Errorf("queue from time 0: mean %f and max %f, the backlog should show", queue.Mean, queue.ObservedMax)
+	}
+
+	// after the warm-up a customer comes every 2 time units and is served at once
+	queue = steady.Objects[0].Places[1]
+	if queue.Mean > 0.01 || queue.ObservedMax > 1 {
+		t.Errorf("queue after warm-up: mean %f and max %f", queue.Mean, queue.ObservedMax)
+	}
+
+	if server := steady.Objects[0].Transitions[1]; server.Mean < 0.74 || server.Mean > 0.76 {
+		t.Errorf("server busy %f of the time after warm-up, want 0.75", server.Mean)
+	}
+}
+
+func TestEstimateWarmUp(t *testing.T) {
+	var gtime petri.GlobalTime
+	model := backlogModel(&gtime)
+	queue := model.Objects[0].Places[1]
+	e, err := model.EstimateWarmUp(3000, 10, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.Series) != 300 || e.Series[0] < 95 {
+		t.Fatalf("%d steps starting at %f, want 300 from 100", len(e.Series), e.Series[0])
+	}
+
+	if e.WarmUp < 450 || e.WarmUp > 750 {
+		t.Errorf("suggested warm-up %f, the backlog is gone by 600", e.WarmUp)
+	}
+
+	if petri.MSER5([]float64{1, 2, 3}) != 0 {
+		t.Error("MSER-5 deleted from a series shorter than two batches")
+	}
+
+	for _, step := range []float64{0, -10, 5000} {
+		if _, err := backlogModel(&petri.GlobalTime{}).EstimateWarmUp(3000, step); err == nil {
+			t.Errorf("estimated the warm-up with steps of %f", step)
+		}
+	}
+}
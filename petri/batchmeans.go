@@ -0,0 +1,185 @@
+package petri
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// BatchMeans analyses one long run: time after Start is split into batches of equal width,
+// every selected place and transition gets the time-weighted mean of its mark or buffer in
+// each batch. The batch means give a confidence interval, their lag-1 autocorrelation tells
+// whether batches are long enough to be taken as independent. With a Precision a serial run
+// stops as soon as every interval is that narrow relative to its mean.
+//
+// Objects of parallel runs fill their series in own time. TimeWarp refuses objects with batch
+// means as rolled back steps would be counted.
+type BatchMeans struct {
+	Batch      float64 // width of a batch
+	Start      float64 // batches start here, the latest warm-up of the objects by default
+	Level      float64 // confidence level, 0.95
+	Precision  float64 // relative half width the serial run stops at, 0 runs until the end
+	MinBatches int     // batches before the run may stop, 10
+
+	Places      []*BatchSeries
+	Transitions []*BatchSeries
+	StopTime    float64 // when the precision was reached, 0 if it wasn't
+
+	sync.Mutex
+	model *Model
+}
+
+type BuildBatchMeans interface {
+	Build(*Model, float64) *BatchMeans
+	AddPlace(*Simulator, string) error
+	AddTransition(*Simulator, string) error
+	Reached() bool
+}
+
+// BatchSeries holds the batch means of a place or a transition
+type BatchSeries struct {
+	Object string
+	Name   string
+	Means  []float64 // of the finished batches
+	Estimate
+	Lag1        float64 // lag-1 autocorrelation of the batch means
+	Independent bool    // Lag1 is within the bounds of independent batches at the level
+
+	value func() float64
+	sum   float64 // integral of the value over the current batch
+	batch int     // index of the current batch
+}
+
+func (b *BatchMeans) Build(m *Model, batch float64) *BatchMeans {
+	b.model = m
+	b.Batch = batch
+	if b.Level <= 0 {
+		b.Level = 0.95
+	}
+	if b.MinBatches <= 0 {
+		b.MinBatches = 10
+	}
+	for _, obj := range m.Objects {
+		b.Start = math.Max(b.Start, obj.WarmUp)
+	}
+
+	return b
+}
+
+// AddPlace collects batch means of the mark of a place of the object, in parallel runs add
+// places after the objects are linked
+func (b *BatchMeans) AddPlace(obj *Simulator, place string) error {
+	i := obj.TNet.FindPlaceByName(place)
+	if i < 0 {
+		return fmt.Errorf("object %s has no place %s", obj.Name, place)
+	}
+
+	series := &BatchSeries{Object: obj.Name, Name: place, value: func() float64 { return obj.Places[i].Mark }}
+	b.Places = append(b.Places, series)
+	b.observe(obj, series)
+	return nil
+}
+
+// AddTransition collects batch means of the busy channels of a transition of the object
+func (b *BatchMeans) AddTransition(obj *Simulator, transition string) error {
+	i := obj.TNet.FindTransitionByName(transition)
+	if i < 0 {
+		return fmt.Errorf("object %s has no transition %s", obj.Name, transition)
+	}
+
+	series := &BatchSeries{Object: obj.Name, Name: transition, value: func() float64 { return float64(obj.Transitions[i].Buffer) }}
+	b.Transitions = append(b.Transitions, series)
+	b.observe(obj, series)
+	return nil
+}
+
+// observe adds the steps of the object to the series, the value holds over each step
+func (b *BatchMeans) observe(obj *Simulator, series *BatchSeries) {
+	obj.observers = append(obj.observers, func(from, to float64) {
+		to = math.Min(to, obj.Gtime.ModTime)
+		from = math.Max(from, b.Start)
+		v := series.value()
+
+		for from < to {
+			end := b.Start + float64(series.batch+1)*b.Batch
+			if end <= from {
+				b.finish(series)
+				continue
+			}
+
+			step := math.Min(to, end) - from
+			series.sum += v * step
+			from += step
+			if from >= end {
+				b.finish(series)
+			}
+		}
+	})
+}
+
+// finish closes the current batch of the series and stops the serial run once the precision is reached
+func (b *BatchMeans) finish(series *BatchSeries) {
+	b.Lock()
+	defer b.Unlock()
+
+	series.Means = append(series.Means, series.sum/b.Batch)
+	series.sum = 0
+	series.batch++
+	series.update(b.Level)
+
+	if b.StopTime == 0 && b.Reached() {
+		b.StopTime = b.Start + float64(series.batch)*b.Batch
+		b.model.halted = true
+	}
+}
+
+func (s *BatchSeries) update(level float64) {
+	var t Tally
+	for _, v := range s.Means {
+		t.Add(v)
+	}
+	s.Estimate = Estimate{N: t.Count, Mean: t.Mean(), Std: t.Std(), HalfWidth: t.HalfWidth(level)}
+
+	// lag-1 autocorrelation of independent batches is about normal with variance 1/n
+	num, den := 0.0, 0.0
+	for i, v := range s.Means {
+		den += (v - s.Mean) * (v - s.Mean)
+		if i > 0 {
+			num += (s.Means[i-1] - s.Mean) * (v - s.Mean)
+		}
+	}
+
+	s.Lag1 = 0
+	if den > 0 {
+		s.Lag1 = num / den
+	}
+	z := math.Sqrt2 * math.Erfinv(level)
+	s.Independent = s.N > 2 && math.Abs(s.Lag1) <= z/math.Sqrt(float64(s.N))
+}
+
+// Relative is the half width of the interval relative to the mean, infinite when the batch
+// means don't vary as a constant series says nothing about the precision
+func (s *BatchSeries) Relative() float64 {
+	if s.HalfWidth == 0 {
+		return math.Inf(1)
+	}
+
+	return s.HalfWidth / math.Abs(s.Mean)
+}
+
+// Reached tells whether every series has enough independent batches for an interval within the precision
+func (b *BatchMeans) Reached() bool {
+	if b.Precision <= 0 || len(b.Places)+len(b.Transitions) == 0 {
+		return false
+	}
+
+	for _, group := range [][]*BatchSeries{b.Places, b.Transitions} {
+		for _, s := range group {
+			if s.N < b.MinBatches || !s.Independent || s.Relative() > b.Precision {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package petri
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Event is the end of service in one channel of a transition
+type Event struct {
+	Time       float64
+	Transition *Transition
+
+	seq    int64 // order of scheduling, on equal times the earlier event goes first
+	index  int   // position in the heap of the transition, -1 once the event is gone
+	tokens []carriedToken
+
+	// what the reset arcs emptied, by index in InPlacesWithReset, for Cancel
+	reset       []float64
+	resetTokens [][]*Token
+}
+
+func (e *Event) Scheduled() bool {
+	return e.index >= 0
+}
+
+// eventHeap holds the busy channels of a transition
+type eventHeap []*Event
+
+func (h eventHeap) Len() int {
+	return len(h)
+}
+
+func (h eventHeap) Less(i, j int) bool {
+	if h[i].Time != h[j].Time {
+		return h[i].Time < h[j].Time
+	}
+
+	return h[i].seq < h[j].seq
+}
+
+func (h eventHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *eventHeap) Push(x interface{}) {
+	e := x.(*Event)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
+
+// EventCalendar is the future event list of a simulator: its transitions ordered by
+// the next end of service. On equal times the transition that comes first in the net
+// goes first, as with a scan of Simulator.Transitions.
+type EventCalendar struct {
+	entries []*calendarEntry
+}
+
+type calendarEntry struct {
+	calendar   *EventCalendar
+	transition *Transition
+	order      int
+	index      int
+}
+
+func (c *EventCalendar) Len() int {
+	return len(c.entries)
+}
+
+func (c *EventCalendar) Less(i, j int) bool {
+	a, b := c.entries[i], c.entries[j]
+	if a.transition.MinTime != b.transition.MinTime {
+		return a.transition.MinTime < b.transition.MinTime
+	}
+
+	return a.order < b.order
+}
+
+func (c *EventCalendar) Swap(i, j int) {
+	c.entries[i], c.entries[j] = c.entries[j], c.entries[i]
+	c.entries[i].index = i
+	c.entries[j].index = j
+}
+
+func (c *EventCalendar) Push(x interface{}) {
+	e := x.(*calendarEntry)
+	e.index = len(c.entries)
+	c.entries = append(c.entries, e)
+}
+
+func (c *EventCalendar) Pop() interface{} {
+	e := c.entries[len(c.entries)-1]
+	c.entries = c.entries[:len(c.entries)-1]
+	e.index = -1
+	return e
+}
+
+// Build puts the transitions into the calendar, they keep it up to date from then on
+func (c *EventCalendar) Build(transitions []*Transition) *EventCalendar {
+	c.entries = nil
+	for i, t := range transitions {
+		t.entry = &calendarEntry{calendar: c, transition: t, order: i, index: i}
+		c.entries = append(c.entries, t.entry)
+	}
+	heap.Init(c)
+
+	return c
+}
+
+func (c *EventCalendar) fix(e *calendarEntry) {
+	heap.Fix(c, e.index)
+}
+
+// Next is the transition with the closest end of service, nil when all channels are free
+func (c *EventCalendar) Next() *Transition {
+	if len(c.entries) == 0 || c.entries[0].transition.MinTime == math.MaxFloat64 {
+		return nil
+	}
+
+	return c.entries[0].transition
+}
+
+// NextTime is the time of the closest end of service, math.MaxFloat64 when there is none
+func (c *EventCalendar) NextTime() float64 {
+	if len(c.entries) == 0 {
+		return math.MaxFloat64
+	}
+
+	return c.entries[0].transition.MinTime
+}
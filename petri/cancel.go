@@ -0,0 +1,138 @@
+package petri
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// runStatus tells what a running object is blocked on, other goroutines read it
+// when the run is cancelled
+type runStatus struct {
+	sync.Mutex
+	done    <-chan struct{}
+	waiting string
+}
+
+func (s *Simulator) setWaiting(what string) {
+	s.status.Lock()
+	s.status.waiting = what
+	s.status.Unlock()
+}
+
+// Waiting describes the channel the object is blocked on, empty while it runs
+func (s *Simulator) Waiting() string {
+	s.status.Lock()
+	defer s.status.Unlock()
+	return s.status.waiting
+}
+
+// stopped tells where the cancelled object was
+func (s *Simulator) stopped() error {
+	what := s.Waiting()
+	s.setWaiting("")
+	if what == "" {
+		return fmt.Errorf("object %s stopped at time %f", s.Name, s.TimeLocal)
+	}
+	return fmt.Errorf("object %s stopped at time %f waiting for %s", s.Name, s.TimeLocal, what)
+}
+
+// cancelled is checked between the steps of the object
+func (s *Simulator) cancelled() error {
+	select {
+	case <-s.status.done:
+		return s.stopped()
+	default:
+		return nil
+	}
+}
+
+// RunContext is Run that ends when the context is cancelled or its deadline passes,
+// the error tells where the object was
+func (s *Simulator) RunContext(ctx context.Context) error {
+	if err := s.runContext(ctx, s.Gtime.ModTime); err != nil {
+		return fmt.Errorf("%v: %v", err, ctx.Err())
+	}
+
+	return nil
+}
+
+func (s *Simulator) runContext(ctx context.Context, end float64) error {
+	s.status.Lock()
+	s.status.done = ctx.Done()
+	s.status.Unlock()
+
+	return s.runUntil(end)
+}
+
+// RunContext runs every object in its own goroutine like Run and waits for all of them,
+// objects sharing places have to be joined by ports first. On cancellation every blocked
+// object is released, the error lists the objects that didn't finish and what they were
+// waiting for.
+func (m *Model) RunContext(ctx context.Context) error {
+	if _, err := portsOf(m.Objects); err != nil {
+		return err
+	}
+
+	if stopped := runObjects(ctx, m.Objects, m.Gtime.ModTime); stopped != "" {
+		return fmt.Errorf("model stopped: %v; %s", ctx.Err(), stopped)
+	}
+
+	return nil
+}
+
+// runObjects runs every object until the end in its own goroutine and waits for all of them,
+// it lists the objects that were stopped
+func runObjects(ctx context.Context, objects []*Simulator, end float64) string {
+	errs := make(chan error, len(objects))
+	for _, obj := range objects {
+		go func(obj *Simulator) {
+			errs <- obj.runContext(ctx, end)
+		}(obj)
+	}
+
+	var stopped []string
+	for range objects {
+		if err := <-errs; err != nil {
+			stopped = append(stopped, err.Error())
+		}
+	}
+
+	sort.Strings(stopped)
+	return strings.Join(stopped, "; ")
+}
+
+// GoRunContext is GoRun that ends when the context is cancelled or its deadline passes
+func (m *Model) GoRunContext(ctx context.Context, timeModeling float64) error {
+	return m.runContext(ctx, func() { m.GoRun(timeModeling) })
+}
+
+// ParallelGoContext is ParallelGo that ends when the context is cancelled or its deadline passes
+func (m *Model) ParallelGoContext(ctx context.Context, timeModeling float64) error {
+	return m.runContext(ctx, func() { m.ParallelGo(timeModeling) })
+}
+
+func (m *Model) runContext(ctx context.Context, run func()) error {
+	m.done = ctx.Done()
+	run()
+	m.done = nil
+
+	if m.stopped {
+		return fmt.Errorf("model stopped at time %f: %v", m.T, ctx.Err())
+	}
+
+	return nil
+}
+
+// cancelled is checked once per event of the serial run, the run ends before the next event
+func (m *Model) cancelled() bool {
+	select {
+	case <-m.done:
+		m.stopped = true
+	default:
+	}
+
+	return m.stopped
+}
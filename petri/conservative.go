@@ -0,0 +1,122 @@
+package petri
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Conservative runs objects joined by ports in parallel as logical processes of a
+// Chandy–Misra–Bryant simulation, every object goes through the loop of Run until EndTime.
+// An object handles an event only when every port into it promises that no earlier
+// firings will come, a blocked object tells the objects it feeds how far they may go with
+// null messages. When all objects are blocked the earliest event of all is safe and the
+// objects move on to it, so runs can't deadlock, also in cycles.
+type Conservative struct {
+	Messages     int64 // firings sent through the ports
+	NullMessages int64
+	Recoveries   int64 // deadlocks broken
+
+	Objects []*Simulator
+	Ports   []*Port // between the objects, see Simulator.Connect and Model.ConnectShared
+	EndTime float64
+
+	// ports of cycles without lookahead, null messages can't move such objects on
+	ZeroLookahead []*Port
+}
+
+type BuildConservative interface {
+	Build([]*Simulator, float64) *Conservative
+	Run(context.Context) error
+}
+
+func (c *Conservative) Build(objects []*Simulator, endTime float64) *Conservative {
+	c.Objects = objects
+	c.Ports = nil
+	c.EndTime = endTime
+	return c
+}
+
+// Run starts a goroutine per object and waits until all reach EndTime, the counters
+// are taken over all runs of the ports. On cancellation the error lists the objects
+// that didn't finish and the objects they were waiting for.
+func (c *Conservative) Run(ctx context.Context) error {
+	ports, err := portsOf(c.Objects)
+	if err != nil {
+		return err
+	}
+	c.Ports = ports
+
+	c.ZeroLookahead = zeroLookahead(ports)
+	if len(c.ZeroLookahead) > 0 {
+		var names []string
+		for _, p := range c.ZeroLookahead {
+			names = append(names, p.From.Name+" -> "+p.To.Name)
+		}
+		log.Printf("ports %s close a cycle without lookahead, set Port.Lookahead or the objects "+
+			"move on only by deadlock recovery", strings.Join(names, ", "))
+	}
+
+	stopped := runObjects(ctx, c.Objects, c.EndTime)
+	c.count()
+	if stopped != "" {
+		return fmt.Errorf("conservative run stopped: %v; %s", ctx.Err(), stopped)
+	}
+
+	return nil
+}
+
+// zeroLookahead lists the ports without lookahead leading back to their sender over such ports
+func zeroLookahead(ports []*Port) []*Port {
+	next := make(map[*Simulator][]*Simulator)
+	for _, p := range ports {
+		if p.Lookahead <= 0 {
+			next[p.From] = append(next[p.From], p.To)
+		}
+	}
+
+	var cycle []*Port
+	for _, p := range ports {
+		if p.Lookahead > 0 {
+			continue
+		}
+
+		seen := map[*Simulator]bool{p.To: true}
+		queue := []*Simulator{p.To}
+		for len(queue) > 0 && !seen[p.From] {
+			for _, s := range next[queue[0]] {
+				if !seen[s] {
+					seen[s] = true
+					queue = append(queue, s)
+				}
+			}
+			queue = queue[1:]
+		}
+
+		if seen[p.From] {
+			cycle = append(cycle, p)
+		}
+	}
+
+	return cycle
+}
+
+// count sums the messages of the mailboxes and the recoveries of the groups of the objects
+func (c *Conservative) count() {
+	c.Messages, c.NullMessages, c.Recoveries = 0, 0, 0
+	for _, p := range c.Ports {
+		c.Messages += p.Mailbox.Sent(TokenArrival)
+		c.NullMessages += p.Mailbox.Sent(NullMessage)
+	}
+
+	groups := make(map[*portGroup]bool)
+	for _, s := range c.Objects {
+		if g := s.group; g != nil && !groups[g] {
+			groups[g] = true
+			g.Lock()
+			c.Recoveries += g.recoveries
+			g.Unlock()
+		}
+	}
+}
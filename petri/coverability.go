@@ -0,0 +1,236 @@
+package petri
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Omega marks a place that can hold any number of markers in a coverability tree
+var Omega = math.Inf(1)
+
+// MarshalJSON writes ω as the string "ω", JSON has no infinity
+func (m Marking) MarshalJSON() ([]byte, error) {
+	values := make([]interface{}, len(m))
+	for i, v := range m {
+		if math.IsInf(v, 1) {
+			values[i] = "ω"
+		} else {
+			values[i] = v
+		}
+	}
+
+	return json.Marshal(values)
+}
+
+func (m *Marking) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	*m = make(Marking, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case float64:
+			(*m)[i] = v
+		case string:
+			if v != "ω" {
+				return fmt.Errorf("bad marking value %q", v)
+			}
+			(*m)[i] = Omega
+		default:
+			return fmt.Errorf("bad marking value %v", v)
+		}
+	}
+
+	return nil
+}
+
+// covers tells whether m is not less than o in every place
+func (m Marking) covers(o Marking) bool {
+	for i := range m {
+		if m[i] < o[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type CoverabilityNode struct {
+	ID      int     `json:"id"`
+	Marking Marking `json:"marking"`
+	Parent  int     `json:"parent"`     // -1 for the root
+	Via     int     `json:"transition"` // index in Transitions fired from Parent, -1 for the root
+
+	// the marking is already in the tree, the node is not expanded
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// CoverabilityTree is the Karp–Miller tree of a net: markings that grow along a path
+// get ω in the growing places, so the tree is finite for any net without inhibitor
+// and reset arcs. Such arcs break the analysis: ω is never below the weight of an
+// inhibitor arc, so the arc keeps its transition disabled for good, and a reset arc
+// empties a place marked ω. The tree of such a net is neither sound nor complete,
+// it may miss reachable markings and bounds and contain unreachable ones.
+type CoverabilityTree struct {
+	Name        string              `json:"name"`
+	Places      []string            `json:"places"`
+	Transitions []string            `json:"transitions"`
+	Nodes       []*CoverabilityNode `json:"nodes"`
+	Complete    bool                `json:"complete"`              // false when the node limit stopped the search
+	Approximate bool                `json:"approximate,omitempty"` // the net has inhibitor or reset arcs
+}
+
+// CoverabilityTree builds the tree breadth first up to maxNodes nodes, maxNodes <= 0 means DefaultMaxStates.
+// For a net with inhibitor or reset arcs the tree is Approximate and returned with an error.
+func (n *Net) CoverabilityTree(maxNodes int) (*CoverabilityTree, error) {
+	if maxNodes <= 0 {
+		maxNodes = DefaultMaxStates
+	}
+
+	s := newNetStructure(n)
+	tree := &CoverabilityTree{Name: n.Name, Places: s.places, Transitions: s.transitions, Complete: true}
+	for t := range s.transitions {
+		if len(s.inhibitor[t]) > 0 || len(s.reset[t]) > 0 {
+			tree.Approximate = true
+		}
+	}
+	seen := make(map[string]bool)
+
+	tree.Nodes = append(tree.Nodes, &CoverabilityNode{ID: 0, Marking: s.initial(n), Parent: -1, Via: -1})
+	for i := 0; i < len(tree.Nodes); i++ {
+		node := tree.Nodes[i]
+		if seen[node.Marking.key()] {
+			node.Duplicate = true
+			continue
+		}
+		seen[node.Marking.key()] = true
+
+		for t := range s.transitions {
+			if !s.enabled(node.Marking, t) {
+				continue
+			}
+
+			if len(tree.Nodes) >= maxNodes {
+				tree.Complete = false
+				break
+			}
+
+			next := s.fire(node.Marking, t)
+
+			// a covered ancestor means the firings between can repeat forever
+			for a := node; ; a = tree.Nodes[a.Parent] {
+				if next.covers(a.Marking) {
+					for p := range next {
+						if next[p] > a.Marking[p] {
+							next[p] = Omega
+						}
+					}
+				}
+
+				if a.Parent < 0 {
+					break
+				}
+			}
+
+			tree.Nodes = append(tree.Nodes, &CoverabilityNode{ID: len(tree.Nodes), Marking: next, Parent: i, Via: t})
+		}
+	}
+
+	if !tree.Complete {
+		return tree, fmt.Errorf("net %s: coverability tree exceeds %d nodes", n.Name, maxNodes)
+	}
+	if tree.Approximate {
+		return tree, fmt.Errorf("net %s has inhibitor or reset arcs, its coverability tree is neither sound nor complete", n.Name)
+	}
+
+	return tree, nil
+}
+
+type PlaceBound struct {
+	Place string
+	Bound float64 // Omega for unbounded places
+}
+
+func (b PlaceBound) Unbounded() bool {
+	return math.IsInf(b.Bound, 1)
+}
+
+func (b PlaceBound) String() string {
+	if b.Unbounded() {
+		return fmt.Sprintf("%s: unbounded, needs a capacity limit", b.Place)
+	}
+
+	return fmt.Sprintf("%s: at most %s", b.Place, formatMarking(b.Bound))
+}
+
+// Bounds gives the largest mark of every place over the tree, the marks of an Approximate tree
+// aren't bounds of the net
+func (t *CoverabilityTree) Bounds() []PlaceBound {
+	bounds := make([]PlaceBound, len(t.Places))
+	for i, name := range t.Places {
+		bounds[i].Place = name
+	}
+
+	for _, node := range t.Nodes {
+		for i, v := range node.Marking {
+			if v > bounds[i].Bound {
+				bounds[i].Bound = v
+			}
+		}
+	}
+
+	return bounds
+}
+
+// Unbounded lists places that need capacity limits
+func (t *CoverabilityTree) Unbounded() []string {
+	var places []string
+	for _, b := range t.Bounds() {
+		if b.Unbounded() {
+			places = append(places, b.Place)
+		}
+	}
+
+	return places
+}
+
+func (t *CoverabilityTree) Bounded() bool {
+	return len(t.Unbounded()) == 0
+}
+
+func WriteCoverabilityDot(w io.Writer, t *CoverabilityTree) error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "digraph %s {\n", quoteDot(t.Name))
+	b.WriteString("  node [shape=box, style=rounded, fontname=\"Helvetica\", fontsize=10];\n  edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	for _, node := range t.Nodes {
+		attrs := ""
+		if node.Duplicate {
+			attrs = ", style=\"rounded,dashed\""
+		}
+		fmt.Fprintf(&b, "  n%d [label=%s%s];\n", node.ID, quoteDot(node.Marking.Format(t.Places)), attrs)
+	}
+
+	for _, node := range t.Nodes {
+		if node.Parent >= 0 {
+			fmt.Fprintf(&b, "  n%d -> n%d [label=%s];\n", node.Parent, node.ID, quoteDot(t.Transitions[node.Via]))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func EncodeCoverabilityJSON(w io.Writer, t *CoverabilityTree) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
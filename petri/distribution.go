@@ -0,0 +1,318 @@
+package petri
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Distribution samples delays of a transition
+type Distribution interface {
+	Sample(RandomStream) float64
+}
+
+// StatefulDistribution is a Distribution whose samples depend on the ones drawn before,
+// like a Trace. TimeWarp saves its state before every event and restores it on rollbacks.
+type StatefulDistribution interface {
+	Distribution
+	State() interface{}
+	SetState(interface{})
+}
+
+// BoundedDistribution is a Distribution that knows a lower bound of its samples, ports take
+// it as the lookahead of own delays set with SetDelay
+type BoundedDistribution interface {
+	Distribution
+	MinDelay() float64
+}
+
+// FiniteDistribution is a Distribution that can run out of samples, like a Trace without Cycle.
+// A transition whose delay is exhausted isn't enabled any more.
+type FiniteDistribution interface {
+	Distribution
+	Exhausted() bool
+}
+
+// DistributionFactory creates a distribution from its named parameters,
+// "mean" and "deviation" are always filled from the transition
+type DistributionFactory func(params map[string]float64) (Distribution, error)
+
+// DistributionFunc turns a plain function into a Distribution
+type DistributionFunc func(RandomStream) float64
+
+func (f DistributionFunc) Sample(r RandomStream) float64 {
+	return f(r)
+}
+
+var distributions = struct {
+	sync.RWMutex
+	factories map[string]DistributionFactory
+}{factories: make(map[string]DistributionFactory)}
+
+// RegisterDistribution makes a distribution available to transitions by name,
+// registering an existing name replaces it
+func RegisterDistribution(name string, f DistributionFactory) {
+	distributions.Lock()
+	distributions.factories[strings.ToLower(name)] = f
+	distributions.Unlock()
+}
+
+func CreateDistribution(name string, params map[string]float64) (Distribution, error) {
+	distributions.RLock()
+	f, ok := distributions.factories[strings.ToLower(name)]
+	distributions.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown distribution %q", name)
+	}
+
+	d, err := f(params)
+	if err != nil {
+		return nil, fmt.Errorf("distribution %s: %v", name, err)
+	}
+
+	return d, nil
+}
+
+func knownDistribution(name string) bool {
+	distributions.RLock()
+	defer distributions.RUnlock()
+	_, ok := distributions.factories[strings.ToLower(name)]
+	return ok
+}
+
+func RegisteredDistributions() []string {
+	distributions.RLock()
+	defer distributions.RUnlock()
+
+	var names []string
+	for name := range distributions.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// param returns a named parameter or its default, missing parameters without defaults are errors
+func param(params map[string]float64, name string, def ...float64) (float64, error) {
+	if v, ok := params[name]; ok {
+		return v, nil
+	}
+
+	if len(def) > 0 {
+		return def[0], nil
+	}
+
+	return 0, fmt.Errorf("parameter %s is missing", name)
+}
+
+func positive(params map[string]float64, name string, def ...float64) (float64, error) {
+	v, err := param(params, name, def...)
+	if err == nil && !(v > 0) {
+		err = fmt.Errorf("parameter %s must be positive, got %f", name, v)
+	}
+
+	return v, err
+}
+
+func init() {
+	constant := func(p map[string]float64) (Distribution, error) {
+		v, err := param(p, "value", p["mean"])
+		if err != nil {
+			return nil, err
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("negative delay %f", v)
+		}
+
+		return DistributionFunc(func(RandomStream) float64 { return v }), nil
+	}
+	RegisterDistribution("const", constant)
+	RegisterDistribution("det", constant)
+
+	RegisterDistribution("exp", func(p map[string]float64) (Distribution, error) {
+		mean, err := positive(p, "mean")
+		if err != nil {
+			return nil, err
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return ExpFrom(r, mean) }), nil
+	})
+
+	RegisterDistribution("unif", func(p map[string]float64) (Distribution, error) {
+		min, err1 := param(p, "min", p["mean"]-p["deviation"])
+		max, err2 := param(p, "max", p["mean"]+p["deviation"])
+		if err1 != nil || err2 != nil || min > max {
+			return nil, fmt.Errorf("bad bounds [%f, %f]", min, max)
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return UniformFrom(r, min, max) }), nil
+	})
+
+	RegisterDistribution("norm", func(p map[string]float64) (Distribution, error) {
+		mean, err := param(p, "mean")
+		if err != nil {
+			return nil, err
+		}
+		deviation, err := param(p, "deviation", 0)
+		if err != nil || deviation < 0 {
+			return nil, fmt.Errorf("bad deviation %f", deviation)
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return NormalFrom(r, mean, deviation) }), nil
+	})
+
+	RegisterDistribution("truncnorm", func(p map[string]float64) (Distribution, error) {
+		mean, err := param(p, "mean")
+		if err != nil {
+			return nil, err
+		}
+		deviation, err := param(p, "deviation", 0)
+		if err != nil || deviation < 0 {
+			return nil, fmt.Errorf("bad deviation %f", deviation)
+		}
+		min, _ := param(p, "min", 0)
+
+		return DistributionFunc(func(r RandomStream) float64 { return TruncatedNormal(r, mean, deviation, min) }), nil
+	})
+
+	RegisterDistribution("erlang", func(p map[string]float64) (Distribution, error) {
+		k, err := positive(p, "k")
+		if err != nil {
+			return nil, err
+		}
+		if k != math.Trunc(k) {
+			return nil, fmt.Errorf("parameter k must be integer, got %f", k)
+		}
+		mean, err := positive(p, "mean")
+		if err != nil {
+			return nil, err
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return Erlang(r, int(k), mean) }), nil
+	})
+
+	RegisterDistribution("gamma", func(p map[string]float64) (Distribution, error) {
+		shape, err := positive(p, "shape")
+		if err != nil {
+			return nil, err
+		}
+		scale, err := positive(p, "scale", p["mean"]/shape)
+		if err != nil {
+			return nil, err
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return Gamma(r, shape, scale) }), nil
+	})
+
+	RegisterDistribution("lognorm", func(p map[string]float64) (Distribution, error) {
+		// mu and sigma of the underlying normal, derived from mean and deviation when omitted
+		var mu, sigma float64
+		if _, ok := p["mu"]; ok {
+			mu = p["mu"]
+			sigma, _ = param(p, "sigma", 0)
+		} else {
+			mean, err := positive(p, "mean")
+			if err != nil {
+				return nil, err
+			}
+			v := math.Log(1 + p["deviation"]*p["deviation"]/(mean*mean))
+			sigma = math.Sqrt(v)
+			mu = math.Log(mean) - v/2
+		}
+		if sigma < 0 {
+			return nil, fmt.Errorf("parameter sigma must not be negative, got %f", sigma)
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return LogNormal(r, mu, sigma) }), nil
+	})
+
+	RegisterDistribution("weibull", func(p map[string]float64) (Distribution, error) {
+		shape, err := positive(p, "shape")
+		if err != nil {
+			return nil, err
+		}
+		scale, err := positive(p, "scale")
+		if err != nil {
+			return nil, err
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return Weibull(r, shape, scale) }), nil
+	})
+
+	RegisterDistribution("triang", func(p map[string]float64) (Distribution, error) {
+		min, err1 := param(p, "min")
+		mode, err2 := param(p, "mode")
+		max, err3 := param(p, "max")
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("parameters min, mode and max are required")
+		}
+		if !(min <= mode && mode <= max && min < max) {
+			return nil, fmt.Errorf("parameters must satisfy min <= mode <= max, got %f, %f, %f", min, mode, max)
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return Triangular(r, min, mode, max) }), nil
+	})
+
+	RegisterDistribution("hyperexp", func(p map[string]float64) (Distribution, error) {
+		prob, err := param(p, "p")
+		if err != nil || prob < 0 || prob > 1 {
+			return nil, fmt.Errorf("parameter p must be in [0, 1]")
+		}
+		mean1, err := positive(p, "mean1")
+		if err != nil {
+			return nil, err
+		}
+		mean2, err := positive(p, "mean2")
+		if err != nil {
+			return nil, err
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 { return HyperExp(r, prob, mean1, mean2) }), nil
+	})
+
+	RegisterDistribution("empiric", func(p map[string]float64) (Distribution, error) {
+		// points of the cumulative distribution function: x0, p0, x1, p1, ...
+		var x, y []float64
+		for i := 0; ; i++ {
+			xi, okX := p["x"+strconv.Itoa(i)]
+			yi, okY := p["p"+strconv.Itoa(i)]
+			if !okX || !okY {
+				break
+			}
+			x = append(x, xi)
+			y = append(y, yi)
+		}
+
+		if err := checkEmpiric(x, y); err != nil {
+			return nil, err
+		}
+
+		return DistributionFunc(func(r RandomStream) float64 {
+			v, _ := EmpiricFrom(r, x, y)
+			return v
+		}), nil
+	})
+}
+
+func checkEmpiric(x []float64, y []float64) error {
+	if len(x) < 2 || len(x) != len(y) {
+		return fmt.Errorf("at least two points x0, p0, x1, p1 are required")
+	}
+
+	if y[0] != 0 || y[len(y)-1] != 1 {
+		return fmt.Errorf("probabilities must go from 0 to 1")
+	}
+
+	for i := 1; i < len(x); i++ {
+		if x[i] < x[i-1] || y[i] < y[i-1] {
+			return fmt.Errorf("points must not decrease")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package petri
+
+import (
+	"sort"
+)
+
+// enablingSet keeps the transitions of a simulator that can fire. A place that changes
+// marks only the transitions reading it for a new check, transitions with guards may
+// read any place and are checked every time.
+type enablingSet struct {
+	transitions []*Transition
+	places      []*Place
+
+	readers [][]int  // per place: transitions whose condition reads it
+	known   []*Place // places the readers are registered with
+
+	active  []int // enabled transitions in the order of the net
+	dirty   []bool
+	queue   []int
+	guarded []int
+}
+
+func (e *enablingSet) build(transitions []*Transition, places []*Place) *enablingSet {
+	e.transitions = transitions
+	e.places = places
+	e.active = nil
+	e.dirty = make([]bool, len(transitions))
+	e.queue = nil
+	e.guarded = nil
+	e.readers = make([][]int, len(places))
+	e.known = make([]*Place, len(places))
+
+	for i, t := range transitions {
+		t.enabling = e
+		t.enablingIndex = i
+
+		for _, group := range [][]int{t.InPlaces, t.InPlacesWithInfo, t.InPlacesWithInhibitor} {
+			for _, p := range group {
+				e.readers[p] = append(e.readers[p], i)
+			}
+		}
+
+		if t.Guard != nil {
+			e.guarded = append(e.guarded, i)
+		}
+		e.touch(i)
+	}
+	e.relink()
+
+	return e
+}
+
+// touch schedules a new check of the transition
+func (e *enablingSet) touch(i int) {
+	if !e.dirty[i] {
+		e.dirty[i] = true
+		e.queue = append(e.queue, i)
+	}
+}
+
+func (e *enablingSet) guard(i int, on bool) {
+	k := sort.SearchInts(e.guarded, i)
+	present := k < len(e.guarded) && e.guarded[k] == i
+	if on && !present {
+		e.guarded = append(e.guarded[:k], append([]int{i}, e.guarded[k:]...)...)
+	} else if !on && present {
+		e.guarded = append(e.guarded[:k], e.guarded[k+1:]...)
+	}
+
+	e.touch(i)
+}
+
+func (e *enablingSet) check(i int) {
+	t := e.transitions[i]
+	on := t.Probability != 0 && t.Condition(e.places)
+
+	k := sort.SearchInts(e.active, i)
+	present := k < len(e.active) && e.active[k] == i
+	if on && !present {
+		e.active = append(e.active, 0)
+		copy(e.active[k+1:], e.active[k:])
+		e.active[k] = i
+	} else if !on && present {
+		e.active = append(e.active[:k], e.active[k+1:]...)
+	}
+}
+
+// relink follows places replaced in the net since the last call, objects of a model
+// share the places they are linked by
+func (e *enablingSet) relink() {
+	for k, p := range e.places {
+		if p == e.known[k] {
+			continue
+		}
+
+		for _, i := range e.readers[k] {
+			if e.known[k] != nil {
+				e.known[k].removeDependent(e.transitions[i])
+			}
+			p.addDependent(e.transitions[i])
+			e.touch(i)
+		}
+		e.known[k] = p
+	}
+}
+
+// enabled checks the touched and guarded transitions and lists the enabled ones
+func (e *enablingSet) enabled() []*Transition {
+	e.relink()
+	for _, i := range e.guarded {
+		e.touch(i)
+	}
+
+	for len(e.queue) > 0 {
+		i := e.queue[len(e.queue)-1]
+		e.queue = e.queue[:len(e.queue)-1]
+		e.dirty[i] = false
+		e.check(i)
+	}
+
+	var transitions []*Transition
+	for _, i := range e.active {
+		transitions = append(transitions, e.transitions[i])
+	}
+
+	return transitions
+}
+
+// addDependent makes changes of the place reach the enabling set of the transition
+func (p *Place) addDependent(t *Transition) {
+	for _, d := range p.dependents {
+		if d == t {
+			return
+		}
+	}
+
+	p.dependents = append(p.dependents, t)
+}
+
+func (p *Place) removeDependent(t *Transition) {
+	for i, d := range p.dependents {
+		if d == t {
+			p.dependents = append(p.dependents[:i], p.dependents[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *Place) changed() {
+	for _, t := range p.dependents {
+		t.touch()
+	}
+}
+
+func (t *Transition) touch() {
+	if t.enabling != nil {
+		t.enabling.touch(t.enablingIndex)
+	}
+}
@@ -0,0 +1,20 @@
+package petri
+
+// Guard is a predicate over the marking of the net, a transition fires only
+// when its arcs and its guard both allow it
+type Guard func(places []*Place) bool
+
+// FireHook is called with the transition, the marking and the time of the firing
+type FireHook func(t *Transition, places []*Place, currentTime float64)
+
+// MarkOf returns the mark of the named place or -1 when the net has no such place,
+// it is a helper for guards
+func MarkOf(places []*Place, name string) float64 {
+	for _, p := range places {
+		if p.Name == name {
+			return p.GetMark()
+		}
+	}
+
+	return -1
+}
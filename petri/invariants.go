@@ -0,0 +1,347 @@
+package petri
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Incidence holds the pre and post matrices of a net, rows are places and columns
+// are transitions in the order of Net.Places and Net.Transitions. Info and inhibitor
+// arcs don't move markers and reset arcs aren't linear, they are left out.
+type Incidence struct {
+	Places      []string
+	Transitions []string
+	Pre         [][]int
+	Post        [][]int
+
+	reset []bool // places emptied by reset arcs
+}
+
+func (n *Net) Incidence() *Incidence {
+	s := newNetStructure(n)
+	m := &Incidence{
+		Places:      s.places,
+		Transitions: s.transitions,
+		Pre:         make([][]int, len(s.places)),
+		Post:        make([][]int, len(s.places)),
+		reset:       make([]bool, len(s.places)),
+	}
+
+	for p := range s.places {
+		m.Pre[p] = make([]int, len(s.transitions))
+		m.Post[p] = make([]int, len(s.transitions))
+	}
+
+	for t := range s.transitions {
+		for _, a := range s.pre[t] {
+			m.Pre[a.place][t] += int(a.weight)
+		}
+
+		for _, a := range s.post[t] {
+			m.Post[a.place][t] += int(a.weight)
+		}
+
+		for _, p := range s.reset[t] {
+			m.reset[p] = true
+		}
+	}
+
+	return m
+}
+
+// Matrix is Post - Pre
+func (m *Incidence) Matrix() [][]int {
+	c := make([][]int, len(m.Places))
+	for p := range m.Places {
+		c[p] = make([]int, len(m.Transitions))
+		for t := range m.Transitions {
+			c[p][t] = m.Post[p][t] - m.Pre[p][t]
+		}
+	}
+
+	return c
+}
+
+// Invariant is a non-negative weighting of places (P-invariant) or transitions (T-invariant)
+type Invariant struct {
+	Weights []int
+	Names   []string
+}
+
+func (v Invariant) Support() []string {
+	var names []string
+	for i, w := range v.Weights {
+		if w != 0 {
+			names = append(names, v.Names[i])
+		}
+	}
+
+	return names
+}
+
+func (v Invariant) String() string {
+	var parts []string
+	for i, w := range v.Weights {
+		if w == 1 {
+			parts = append(parts, v.Names[i])
+		} else if w != 0 {
+			parts = append(parts, strconv.Itoa(w)+"*"+v.Names[i])
+		}
+	}
+
+	return strings.Join(parts, " + ")
+}
+
+// Value weights the marking, for a P-invariant it stays the same whatever fires
+func (v Invariant) Value(m Marking) float64 {
+	sum := 0.0
+	for i, w := range v.Weights {
+		sum += float64(w) * m[i]
+	}
+
+	return sum
+}
+
+// PInvariants are the minimal y >= 0 with y C = 0: weighted sums of markers that
+// no firing changes. Invariants over places with reset arcs don't hold and are dropped.
+func (m *Incidence) PInvariants() []Invariant {
+	var invariants []Invariant
+	for _, w := range farkas(m.Matrix()) {
+		ok := true
+		for p, v := range w {
+			if v != 0 && m.reset[p] {
+				ok = false
+			}
+		}
+
+		if ok {
+			invariants = append(invariants, Invariant{Weights: w, Names: m.Places})
+		}
+	}
+
+	return invariants
+}
+
+// TInvariants are the minimal x >= 0 with C x = 0: firing counts that lead back to the same marking
+func (m *Incidence) TInvariants() []Invariant {
+	c := m.Matrix()
+	ct := make([][]int, len(m.Transitions))
+	for t := range m.Transitions {
+		ct[t] = make([]int, len(m.Places))
+		for p := range m.Places {
+			ct[t][p] = c[p][t]
+		}
+	}
+
+	var invariants []Invariant
+	for _, w := range farkas(ct) {
+		invariants = append(invariants, Invariant{Weights: w, Names: m.Transitions})
+	}
+
+	return invariants
+}
+
+// farkas returns the minimal-support non-negative integer solutions y of y a = 0
+func farkas(a [][]int) [][]int {
+	n := len(a)
+	if n == 0 {
+		return nil
+	}
+	cols := len(a[0])
+
+	// every row is a row of a followed by a row of the identity
+	var rows [][]int
+	for i := 0; i < n; i++ {
+		row := make([]int, cols+n)
+		copy(row, a[i])
+		row[cols+i] = 1
+		rows = append(rows, row)
+	}
+
+	for j := 0; j < cols; j++ {
+		var next [][]int
+		for _, r := range rows {
+			if r[j] == 0 {
+				next = append(next, r)
+			}
+		}
+
+		for x, r := range rows {
+			if r[j] <= 0 {
+				continue
+			}
+
+			for y, q := range rows {
+				if q[j] >= 0 || x == y {
+					continue
+				}
+
+				combined := make([]int, len(r))
+				for k := range r {
+					combined[k] = -q[j]*r[k] + r[j]*q[k]
+				}
+				next = append(next, normalize(combined))
+			}
+		}
+
+		rows = minimalRows(next, cols)
+	}
+
+	var solutions [][]int
+	for _, r := range rows {
+		solutions = append(solutions, r[cols:])
+	}
+
+	return solutions
+}
+
+func normalize(r []int) []int {
+	g := 0
+	for _, v := range r {
+		g = gcd(g, v)
+	}
+
+	if g > 1 {
+		for k := range r {
+			r[k] /= g
+		}
+	}
+
+	return r
+}
+
+func gcd(a int, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// minimalRows drops rows whose support in the identity part contains the support of another row
+func minimalRows(rows [][]int, from int) [][]int {
+	contains := func(r []int, q []int) bool {
+		for k := from; k < len(r); k++ {
+			if q[k] != 0 && r[k] == 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	var minimal [][]int
+	for i, r := range rows {
+		keep := true
+		for k, q := range rows {
+			if i == k || !contains(r, q) {
+				continue
+			}
+
+			// of rows with equal supports the first one stays
+			if !contains(q, r) || k < i {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			minimal = append(minimal, r)
+		}
+	}
+
+	return minimal
+}
+
+// invariantCheck asserts that P-invariants hold while the object runs, markers
+// inside transitions count with the weights of the places they were taken from
+type invariantCheck struct {
+	invariants []Invariant
+	inFlight   [][]float64 // per invariant and transition: weight of one firing in progress
+	values     []float64
+}
+
+// newInvariantCheck skips invariants over the shared places, other objects change them
+func (s *Simulator) newInvariantCheck(shared map[*Place]bool) *invariantCheck {
+	m := s.TNet.Incidence()
+
+	c := &invariantCheck{}
+	for _, v := range m.PInvariants() {
+		ok := true
+		for p, w := range v.Weights {
+			if w != 0 && shared[s.Places[p]] {
+				ok = false
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		weights := make([]float64, len(m.Transitions))
+		for t := range m.Transitions {
+			for p, w := range v.Weights {
+				weights[t] += float64(w * m.Pre[p][t])
+			}
+		}
+
+		c.invariants = append(c.invariants, v)
+		c.inFlight = append(c.inFlight, weights)
+	}
+
+	c.values = c.current(s)
+	return c
+}
+
+func (c *invariantCheck) current(s *Simulator) []float64 {
+	m := make(Marking, len(s.Places))
+	for i, p := range s.Places {
+		m[i] = p.GetMark()
+	}
+
+	values := make([]float64, len(c.invariants))
+	for i, v := range c.invariants {
+		values[i] = v.Value(m)
+		for t, tr := range s.Transitions {
+			values[i] += float64(tr.Buffer) * c.inFlight[i][t]
+		}
+	}
+
+	return values
+}
+
+// SetInvariantCheck makes the object assert after every step that its P-invariants hold,
+// it is meant for tests and debugging. Invariants over places of ports are skipped, so call
+// it after the objects are linked.
+func (s *Simulator) SetInvariantCheck(on bool) BuildSimulator {
+	s.setInvariantCheck(on, s.portPlaces())
+	return s
+}
+
+func (s *Simulator) setInvariantCheck(on bool, shared map[*Place]bool) {
+	s.invariants = nil
+	if on {
+		s.invariants = s.newInvariantCheck(shared)
+	}
+}
+
+func (s *Simulator) checkInvariants() {
+	if s.invariants == nil {
+		return
+	}
+
+	for i, v := range s.invariants.current(s) {
+		if math.Abs(v-s.invariants.values[i]) > 1e-9 {
+			log.Panicf("object %s: invariant %s changed from %f to %f at time %f",
+				s.Name, s.invariants.invariants[i], s.invariants.values[i], v, s.TimeLocal)
+		}
+	}
+}
@@ -13,8 +13,11 @@ type Linker struct {
 	NameTransition     string
 	CounterTransitions int
 
-	KVariant int
-	Info     bool
+	KVariant  int
+	Info      bool
+	Inhibitor bool   // transition is enabled only while the place has less than KVariant markers
+	Reset     bool   // place is emptied when the transition fires
+	Class     string // class of tokens of a coloured place the link counts, empty for any
 
 	Number int
 	Label  string
@@ -41,6 +44,13 @@ type BuildLink interface {
 	InitNext(*GlobalCounter) BuildLink
 	IsInfo() bool
 	SetInfo(bool) BuildLink
+	IsInhibitor() bool
+	SetInhibitor(bool) BuildLink
+	IsReset() bool
+	SetReset(bool) BuildLink
+	GetClass() string
+	SetClass(string) BuildLink
+	Kind() string
 
 	PrintInfo()
 	PrintParams()
@@ -140,9 +150,52 @@ func (l *Linker) SetInfo(i bool) BuildLink {
 	return l
 }
 
+func (l *Linker) IsInhibitor() bool {
+	return l.Inhibitor
+}
+
+func (l *Linker) SetInhibitor(i bool) BuildLink {
+	l.Inhibitor = i
+	return l
+}
+
+func (l *Linker) IsReset() bool {
+	return l.Reset
+}
+
+func (l *Linker) SetReset(r bool) BuildLink {
+	l.Reset = r
+	return l
+}
+
+func (l *Linker) GetClass() string {
+	return l.Class
+}
+
+func (l *Linker) SetClass(c string) BuildLink {
+	l.Class = c
+	return l
+}
+
+// Kind names the arc as in net files: in, info, inhibitor, reset or out
+func (l *Linker) Kind() string {
+	switch {
+	case l.Label == `o`:
+		return ArcOut
+	case l.Inhibitor:
+		return ArcInhibitor
+	case l.Reset:
+		return ArcReset
+	case l.Info:
+		return ArcInfo
+	}
+
+	return ArcIn
+}
+
 func (l *Linker) PrintInfo() {
-	fmt.Printf("%s\n%+v\n",
-		strings.Repeat("=", 10), l,
+	fmt.Printf("%s %s\n%+v\n",
+		strings.Repeat("=", 10), l.Kind(), l,
 	)
 }
 
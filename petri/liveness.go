@@ -0,0 +1,465 @@
+package petri
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Deadlock is a reachable marking where no transition is enabled
+type Deadlock struct {
+	Marking Marking
+	Path    []string // shortest firing sequence from the initial marking
+}
+
+// Siphon is a set of places that, once empty, stays empty. It can't empty when
+// it holds a marked trap.
+type Siphon struct {
+	Places     []string
+	Marked     bool     // initially
+	Trap       []string // largest trap inside the siphon
+	TrapMarked bool
+}
+
+type LivenessReport struct {
+	Net       string
+	Places    []string
+	Deadlocks []Deadlock
+
+	// transitions that never fire from the initial marking and transitions that
+	// can't fire any more after some firing sequence
+	DeadTransitions []string
+	NotLive         []string
+
+	Siphons []Siphon
+	Traps   [][]string // minimal traps
+
+	Complete        bool // the state space was explored completely
+	SiphonsComplete bool // the search for siphons and traps didn't stop at the limit
+}
+
+// CheckLiveness looks for deadlocks and dead transitions in the reachability graph and
+// for siphons that can empty. When the graph exceeds maxStates the deadlocks found so far
+// are reported, dead transitions come from the coverability tree and liveness is not decided.
+// The tree of a net with inhibitor or reset arcs is approximate, then the error tells so.
+// The search for siphons and traps visits at most maxStates sets of places each.
+func (n *Net) CheckLiveness(maxStates int) (*LivenessReport, error) {
+	if maxStates <= 0 {
+		maxStates = DefaultMaxStates
+	}
+
+	s := newNetStructure(n)
+	r := &LivenessReport{Net: n.Name, Places: s.places, Complete: true}
+
+	g, err := n.ReachabilityGraph(maxStates)
+	for _, d := range g.Dead() {
+		r.Deadlocks = append(r.Deadlocks, Deadlock{Marking: g.States[d].Marking, Path: g.Path(d)})
+	}
+
+	fired := make([]bool, len(s.transitions))
+	if err == nil {
+		for _, e := range g.Edges {
+			fired[e.Transition] = true
+		}
+
+		r.NotLive = notLive(g)
+	} else {
+		r.Complete = false
+		tree, err := n.CoverabilityTree(maxStates)
+		if err != nil {
+			return r, err
+		}
+
+		for _, node := range tree.Nodes {
+			if node.Via >= 0 {
+				fired[node.Via] = true
+			}
+		}
+	}
+
+	for t, ok := range fired {
+		if !ok {
+			r.DeadTransitions = append(r.DeadTransitions, s.transitions[t])
+		}
+	}
+
+	initial := s.initial(n)
+	marked := func(places []int) bool {
+		for _, p := range places {
+			if initial[p] > 0 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	in, out := s.placeArcs()
+	siphons, complete := minimalSiphons(len(s.places), in, out, maxStates)
+	for _, siphon := range siphons {
+		trap := maximalSiphon(siphon, out, in)
+		r.Siphons = append(r.Siphons, Siphon{
+			Places:     s.names(siphon),
+			Marked:     marked(siphon),
+			Trap:       s.names(trap),
+			TrapMarked: marked(trap),
+		})
+	}
+
+	// traps are siphons of the net with reversed arcs
+	traps, trapsComplete := minimalSiphons(len(s.places), out, in, maxStates)
+	for _, trap := range traps {
+		r.Traps = append(r.Traps, s.names(trap))
+	}
+	r.SiphonsComplete = complete && trapsComplete
+
+	return r, nil
+}
+
+func (s *netStructure) names(places []int) []string {
+	var names []string
+	for _, p := range places {
+		names = append(names, s.places[p])
+	}
+
+	return names
+}
+
+// placeArcs gives for every place the transitions putting markers into it and taking them
+// from it. Info arcs read the place and are counted both ways, inhibitor and reset arcs are left out.
+func (s *netStructure) placeArcs() (in [][]int, out [][]int) {
+	in = make([][]int, len(s.places))
+	out = make([][]int, len(s.places))
+
+	for t := range s.transitions {
+		for _, a := range s.pre[t] {
+			out[a.place] = append(out[a.place], t)
+		}
+
+		for _, a := range s.info[t] {
+			out[a.place] = append(out[a.place], t)
+			in[a.place] = append(in[a.place], t)
+		}
+
+		for _, a := range s.post[t] {
+			in[a.place] = append(in[a.place], t)
+		}
+	}
+
+	return in, out
+}
+
+// transitionInputs inverts out: places each transition takes markers from
+func transitionInputs(out [][]int) map[int][]int {
+	inputs := make(map[int][]int)
+	for p, ts := range out {
+		for _, t := range ts {
+			inputs[t] = append(inputs[t], p)
+		}
+	}
+
+	return inputs
+}
+
+// minimalSiphons finds sets S with every transition putting into S also taking from S.
+// For each place the search adds inputs of transitions that break the condition,
+// one branch per input place. Sets already searched are skipped, complete is false when
+// more than limit sets would be searched.
+func minimalSiphons(places int, in [][]int, out [][]int, limit int) (minimal [][]int, complete bool) {
+	inputs := transitionInputs(out)
+	var found [][]int
+	visited := make(map[string]bool)
+	complete = true
+
+	var search func(set map[int]bool)
+	search = func(set map[int]bool) {
+		var sorted []int
+		for p := range set {
+			sorted = append(sorted, p)
+		}
+		sort.Ints(sorted)
+
+		key := fmt.Sprint(sorted)
+		if visited[key] {
+			return
+		}
+		if len(visited) >= limit {
+			complete = false
+			return
+		}
+		visited[key] = true
+
+		// a transition feeding the set without taking from it
+		broken := -1
+		for p := range set {
+			for _, t := range in[p] {
+				taking := false
+				for _, q := range inputs[t] {
+					if set[q] {
+						taking = true
+						break
+					}
+				}
+
+				if !taking && (broken < 0 || t < broken) {
+					broken = t
+				}
+			}
+		}
+
+		if broken < 0 {
+			found = append(found, sorted)
+			return
+		}
+
+		for _, q := range inputs[broken] {
+			next := make(map[int]bool)
+			for p := range set {
+				next[p] = true
+			}
+			next[q] = true
+			search(next)
+		}
+	}
+
+	for p := 0; p < places; p++ {
+		search(map[int]bool{p: true})
+	}
+
+	for i, a := range found {
+		keep := true
+		for j, b := range found {
+			if i != j && len(b) < len(a) && subset(b, a) {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			minimal = append(minimal, a)
+		}
+	}
+
+	sort.Slice(minimal, func(i, j int) bool {
+		if len(minimal[i]) != len(minimal[j]) {
+			return len(minimal[i]) < len(minimal[j])
+		}
+
+		return fmt.Sprint(minimal[i]) < fmt.Sprint(minimal[j])
+	})
+
+	return minimal, complete
+}
+
+func subset(a []int, b []int) bool {
+	set := make(map[int]bool)
+	for _, p := range b {
+		set[p] = true
+	}
+
+	for _, p := range a {
+		if !set[p] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// maximalSiphon is the largest siphon inside the places, called with in and out
+// swapped it is the largest trap
+func maximalSiphon(places []int, in [][]int, out [][]int) []int {
+	inputs := transitionInputs(out)
+	set := make(map[int]bool)
+	for _, p := range places {
+		set[p] = true
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for p := range set {
+			for _, t := range in[p] {
+				taking := false
+				for _, q := range inputs[t] {
+					if set[q] {
+						taking = true
+						break
+					}
+				}
+
+				if !taking {
+					delete(set, p)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var result []int
+	for p := range set {
+		result = append(result, p)
+	}
+	sort.Ints(result)
+
+	return result
+}
+
+// notLive lists transitions missing from some terminal strongly connected component:
+// once the net gets there they never fire again
+func notLive(g *ReachabilityGraph) []string {
+	n := len(g.States)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	component := make([]int, n)
+	for i := range index {
+		index[i] = -1
+	}
+
+	var stack []int
+	counter := 0
+	components := 0
+
+	// Tarjan's algorithm without recursion, the graph can be deep
+	type frame struct {
+		state int
+		edge  int
+	}
+
+	for root := 0; root < n; root++ {
+		if index[root] >= 0 {
+			continue
+		}
+
+		frames := []frame{{state: root}}
+		index[root], low[root] = counter, counter
+		counter++
+		stack = append(stack, root)
+		onStack[root] = true
+
+		for len(frames) > 0 {
+			f := &frames[len(frames)-1]
+			if f.edge < len(g.out[f.state]) {
+				to := g.Edges[g.out[f.state][f.edge]].To
+				f.edge++
+
+				if index[to] < 0 {
+					index[to], low[to] = counter, counter
+					counter++
+					stack = append(stack, to)
+					onStack[to] = true
+					frames = append(frames, frame{state: to})
+				} else if onStack[to] && index[to] < low[f.state] {
+					low[f.state] = index[to]
+				}
+				continue
+			}
+
+			v := f.state
+			frames = frames[:len(frames)-1]
+			if len(frames) > 0 {
+				parent := frames[len(frames)-1].state
+				if low[v] < low[parent] {
+					low[parent] = low[v]
+				}
+			}
+
+			if low[v] == index[v] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					component[w] = components
+					if w == v {
+						break
+					}
+				}
+				components++
+			}
+		}
+	}
+
+	terminal := make([]bool, components)
+	for i := range terminal {
+		terminal[i] = true
+	}
+
+	fired := make([][]bool, components)
+	for i := range fired {
+		fired[i] = make([]bool, len(g.Transitions))
+	}
+
+	for _, e := range g.Edges {
+		if component[e.From] != component[e.To] {
+			terminal[component[e.From]] = false
+		} else {
+			fired[component[e.From]][e.Transition] = true
+		}
+	}
+
+	var names []string
+	for t, name := range g.Transitions {
+		for c := range terminal {
+			if terminal[c] && !fired[c][t] {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	return names
+}
+
+func (r *LivenessReport) Deadlocked() bool {
+	return len(r.Deadlocks) > 0
+}
+
+func (r *LivenessReport) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "net %s:\n", r.Net)
+	if !r.Complete {
+		b.WriteString("  state space is too large, deadlocks are incomplete and liveness is not decided\n")
+	}
+	if !r.SiphonsComplete {
+		b.WriteString("  too many sets of places, siphons and traps are incomplete\n")
+	}
+
+	if len(r.Deadlocks) == 0 && r.Complete {
+		b.WriteString("  no deadlocks\n")
+	}
+
+	for _, d := range r.Deadlocks {
+		path := "initial marking"
+		if len(d.Path) > 0 {
+			path = strings.Join(d.Path, " -> ")
+		}
+		fmt.Fprintf(&b, "  deadlock %s after %s\n", d.Marking.Format(r.Places), path)
+	}
+
+	if len(r.DeadTransitions) > 0 {
+		fmt.Fprintf(&b, "  dead transitions: %s\n", strings.Join(r.DeadTransitions, ", "))
+	}
+
+	if len(r.NotLive) > 0 {
+		fmt.Fprintf(&b, "  not live: %s\n", strings.Join(r.NotLive, ", "))
+	}
+
+	for _, s := range r.Siphons {
+		state := "holds a marked trap"
+		if !s.TrapMarked {
+			state = "can empty"
+			if !s.Marked {
+				state = "is empty"
+			}
+		}
+		fmt.Fprintf(&b, "  siphon {%s} %s\n", strings.Join(s.Places, ", "), state)
+	}
+
+	for _, t := range r.Traps {
+		fmt.Fprintf(&b, "  trap {%s}\n", strings.Join(t, ", "))
+	}
+
+	return b.String()
+}
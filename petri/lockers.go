@@ -11,12 +11,26 @@ type GlobalCounter struct {
 	Simulator  int
 }
 
+// Reset starts numbering of places, transitions and links over for the next net,
+// simulators keep their numbers
+func (c *GlobalCounter) Reset() {
+	c.Lock()
+	c.LinkIn = 0
+	c.LinkOut = 0
+	c.Place = 0
+	c.Transition = 0
+	c.Unlock()
+}
+
 type GlobalTime struct {
 	sync.Mutex
 	CurrentTime float64
 	ModTime     float64
 }
 
+// GlobalLocker used to wake objects waiting for each other, ports do it now.
+//
+// Deprecated: Simulator.Build ignores it, pass nil.
 type GlobalLocker struct {
 	Cond *sync.Cond
 }
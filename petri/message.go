@@ -0,0 +1,100 @@
+package petri
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// MessageKind tells what a message of a port carries
+type MessageKind int
+
+const (
+	TokenArrival    MessageKind = iota // Count markers arrive at Time
+	NullMessage                        // no markers, promises that none come before Time
+	EndOfSimulation                    // the sender is done, no more markers come
+	AntiMessage                        // cancels the markers sent before, see TimeWarp
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case TokenArrival:
+		return "token arrival"
+	case NullMessage:
+		return "null"
+	case EndOfSimulation:
+		return "end of simulation"
+	case AntiMessage:
+		return "anti-message"
+	}
+
+	return fmt.Sprintf("message kind %d", int(k))
+}
+
+// Message is what a port passes from the object of its transition to the object of its place
+type Message struct {
+	Kind   MessageKind
+	Time   float64
+	Count  int
+	Tokens []*Token // taken by the firing from coloured places
+}
+
+// put delivers the markers into the place, a coloured place gets new tokens for markers sent without one
+func (m Message) put(p *Place, now float64) {
+	p.IncrMark(float64(m.Count))
+	if p.IsColoured() {
+		tokens := m.Tokens
+		for len(tokens) < m.Count {
+			tokens = append(tokens, p.newToken("", m.Time))
+		}
+		p.PutTokens(tokens, now)
+	}
+}
+
+// Mailbox is the bounded queue of messages of a port, the sender waits while it is full
+type Mailbox struct {
+	messages chan Message
+	waits    int64
+	sent     [AntiMessage]int64 // by kind, anti-messages don't go through mailboxes
+}
+
+func newMailbox(size int) *Mailbox {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Mailbox{messages: make(chan Message, size)}
+}
+
+// Len is the number of messages sent and not taken yet
+func (b *Mailbox) Len() int {
+	return len(b.messages)
+}
+
+// Cap is the number of messages the mailbox holds before the sender waits
+func (b *Mailbox) Cap() int {
+	return cap(b.messages)
+}
+
+// Waits counts the times the sender found the mailbox full
+func (b *Mailbox) Waits() int64 {
+	return atomic.LoadInt64(&b.waits)
+}
+
+// Sent counts the messages of the kind put into the mailbox
+func (b *Mailbox) Sent(kind MessageKind) int64 {
+	if kind < 0 || kind >= AntiMessage {
+		return 0
+	}
+
+	return atomic.LoadInt64(&b.sent[kind])
+}
+
+// take returns a message if there is one
+func (b *Mailbox) take() (Message, bool) {
+	select {
+	case m := <-b.messages:
+		return m, true
+	default:
+		return Message{}, false
+	}
+}
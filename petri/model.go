@@ -1,9 +1,9 @@
 package petri
 
 import (
+	"context"
 	"log"
 	"math"
-	"math/rand"
 	"sort"
 	"sync"
 )
@@ -15,6 +15,13 @@ type Model struct {
 	T               float64
 	IsProtocolPrint bool
 	IsStatistics    bool
+
+	Random RandomStream
+
+	done      <-chan struct{}          // see GoRunContext
+	stopped   bool                     // the run was cancelled
+	observers []func(from, to float64) // see EstimateWarmUp
+	halted    bool                     // ends the serial run before its time, see BatchMeans
 }
 
 type BuildModel interface {
@@ -22,9 +29,17 @@ type BuildModel interface {
 	GetNextEventTime() float64
 	ModelInput()
 	SortObj([]*Simulator)
+	SetSeed(int64) *Model
+	SetColoured(bool) *Model
+	SetInvariantCheck(bool) *Model
+	SetWarmUp(float64) *Model
+	ConnectShared() error
 	ChooseObj([]*Simulator) *Simulator
 	ParallelGo(float64)
 	GoRun(float64)
+	ParallelGoContext(context.Context, float64) error
+	GoRunContext(context.Context, float64) error
+	RunContext(context.Context) error
 }
 
 func (m *Model) Build(s []*Simulator, gtime *GlobalTime) *Model {
@@ -33,23 +48,116 @@ func (m *Model) Build(s []*Simulator, gtime *GlobalTime) *Model {
 	m.TimeMod = math.MaxFloat64 - 1
 	m.IsProtocolPrint = true
 	m.IsStatistics = true
+	m.numberTokens()
+	return m
+}
+
+// numberTokens makes all objects count tokens together from the start, tokens passed
+// between objects keep distinct identities
+func (m *Model) numberTokens() {
+	c := &tokenCounter{}
+	for _, obj := range m.Objects {
+		numberTokens(obj.Places, c)
+	}
+}
+
+// SetSeed makes runs reproducible: the model and every object with its transitions
+// get own streams derived from the seed and tokens are numbered from the start
+func (m *Model) SetSeed(seed int64) *Model {
+	m.numberTokens()
+	st := &Streams{Seed: seed}
+	m.Random = st.Stream("model")
+	for _, obj := range m.Objects {
+		obj.SetStreams(st)
+	}
+
+	return m
+}
+
+// SetColoured keeps token records in all places of all objects, places shared
+// by linked objects pass tokens on so latencies are measured along the whole chain
+func (m *Model) SetColoured(c bool) *Model {
+	for _, obj := range m.Objects {
+		obj.TNet.SetColoured(c)
+	}
+
+	return m
+}
+
+// SetInvariantCheck turns the P-invariant assertion of every object on or off, invariants
+// over places objects share or link by ports are skipped
+func (m *Model) SetInvariantCheck(on bool) *Model {
+	shared := make(map[*Place]bool)
+	owner := make(map[*Place]*Simulator)
+	for _, obj := range m.Objects {
+		for _, p := range obj.Places {
+			if o, ok := owner[p]; ok && o != obj {
+				shared[p] = true
+			}
+			owner[p] = obj
+		}
+
+		for p := range obj.portPlaces() {
+			shared[p] = true
+		}
+	}
+
+	for _, obj := range m.Objects {
+		obj.setInvariantCheck(on, shared)
+	}
+
 	return m
 }
 
+// SetWarmUp makes statistics of all objects start over at the time
+func (m *Model) SetWarmUp(t float64) *Model {
+	for _, obj := range m.Objects {
+		obj.SetWarmUp(t)
+	}
+
+	return m
+}
+
+// doStatistics adds the step of the serial run to the statistics, objects share places,
+// so all of them start over before any adds the step
+func (m *Model) doStatistics(from, to float64) {
+	for _, f := range m.observers {
+		f(from, to)
+	}
+
+	for _, obj := range m.Objects {
+		obj.observe(from, to)
+		obj.warmUp(from, to)
+	}
+
+	for _, obj := range m.Objects {
+		obj.accumulate(from, to)
+	}
+}
+
 func (m *Model) GetNextEventTime() float64 {
 	min := m.Objects[0].TimeMin
 
 	for i := 0; i < len(m.Objects); i++ {
 		if m.Objects[i].TimeMin < min {
-			min = m.TimeMod
+			min = m.Objects[i].TimeMin
 		}
 	}
 
 	return min
 }
 
+// ModelInput lets every object enter markers into its transitions, objects sharing
+// places do it one after another in the order of their priorities
 func (m *Model) ModelInput() {
 	m.SortObj(m.Objects)
+	if sharedPlace(m.Objects) != nil {
+		for _, obj := range m.Objects {
+			obj.Input()
+		}
+		return
+	}
+
 	var wg sync.WaitGroup
 
 	for i := 0; i < len(m.Objects); i++ {
@@ -87,7 +195,7 @@ func (m *Model) ChooseObj(s []*Simulator) *Simulator {
 		if max == 0 {
 			num = 0
 		} else {
-			num = rand.Intn(max)
+			num = streamOrGlobal(m.Random).Intn(max)
 		}
 	} else {
 		num = 0
@@ -100,6 +208,8 @@ func (m *Model) ParallelGo(timeModeling float64) {
 	m.TimeMod = timeModeling
 
 	m.T = 0.0
+	m.halted = false
+	m.stopped = false
 	var min float64
 
 	if m.IsProtocolPrint {
@@ -108,7 +218,7 @@ func (m *Model) ParallelGo(timeModeling float64) {
 	}
 
 	var conflictObj []*Simulator
-	for m.T < timeModeling {
+	for m.T < timeModeling && !m.halted && !m.cancelled() {
 		conflictObj = []*Simulator{}
 
 		// maybe Conditions changed
@@ -121,17 +231,17 @@ func (m *Model) ParallelGo(timeModeling float64) {
 		// search the closest event
 		min = m.GetNextEventTime()
 		if m.IsStatistics {
-			for i := 0; i < len(m.Objects); i++ {
-				// statistics within delta m.T
-				// statistics is collected only once for all common positions
-				m.Objects[i].DoStatisticsWithInterval((min - m.T) / min)
-			}
+			// statistics within delta m.T
+			m.doStatistics(m.T, min)
 		}
 
 		// pass time further
 		m.T = min
 
 		m.Gtime.CurrentTime = m.T
+		for i := 0; i < len(m.Objects); i++ {
+			m.Objects[i].DoT()
+		}
 
 		if m.IsProtocolPrint {
 			log.Printf("Passing time further. m.T: %f", m.T)
@@ -177,6 +287,8 @@ func (m *Model) GoRun(timeModeling float64) {
 	m.Gtime.Unlock()
 
 	m.T = 0.0
+	m.halted = false
+	m.stopped = false
 	var min float64
 
 	m.SortObj(m.Objects)
@@ -192,19 +304,20 @@ func (m *Model) GoRun(timeModeling float64) {
 	}
 
 	var K []*Simulator
-	for m.T < timeModeling {
+	for m.T < timeModeling && !m.halted && !m.cancelled() {
 		K = []*Simulator{}
 
 		min = m.GetNextEventTime()
 		if m.IsStatistics {
-			for i := 0; i < len(m.Objects); i++ {
-				m.Objects[i].DoStatisticsWithInterval((min - m.T) / min)
-			}
+			m.doStatistics(m.T, min)
 		}
 
 		// time forward
 		m.T = min
 		m.Gtime.CurrentTime = m.T
+		for i := 0; i < len(m.Objects); i++ {
+			m.Objects[i].DoT()
+		}
 
 		if m.IsProtocolPrint {
 			log.Printf("Pass time through m.T: %f\n", m.T)
@@ -241,7 +354,7 @@ func (m *Model) GoRun(timeModeling float64) {
 				if max == 0 {
 					num = 0
 				} else {
-					num = rand.Intn(max)
+					num = streamOrGlobal(m.Random).Intn(max)
 				}
 			} else {
 				num = 0
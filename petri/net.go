@@ -2,6 +2,7 @@ package petri
 
 import (
 	"fmt"
+	"log"
 )
 
 type Net struct {
@@ -19,6 +20,8 @@ type Net struct {
 
 type BuildNet interface {
 	Build(string, []*Place, []*Transition, []*Linker, []*Linker) Net
+	BuildChecked(string, []*Place, []*Transition, []*Linker, []*Linker) (Net, error)
+	Validate() Problems
 	FindPlaceByName(string) int
 	FindTransitionByName(string) int
 	GetCurrentMark(string) float64
@@ -44,17 +47,14 @@ func (n *Net) Build(name string, places []*Place, transitions []*Transition, lin
 	n.Transitions = transitions
 	n.LinksIn = linksIn
 	n.LinksOut = linksOut
+	numberTokens(places, &tokenCounter{})
 
 	for i := 0; i < len(n.Transitions); i++ {
 		n.Transitions[i].CreateInPlaces(places, linksIn)
 		n.Transitions[i].CreateOutPlaces(places, linksOut)
 
-		if len(n.Transitions[i].InPlaces) == 0 {
-			//log.Println(fmt.Errorf("error: Transition %s has empty list of input places", n.Transitions[i].Name))
-		}
-
-		if len(n.Transitions[i].OutPlaces) == 0 {
-			//log.Println(fmt.Errorf("error: Transition %s has empty list of output places", n.Transitions[i].Name))
+		if err := n.Transitions[i].DistributionError(); err != nil {
+			log.Printf("net %s: transition %s: %v, it serves in the mean time %f", name, n.Transitions[i].Name, err, n.Transitions[i].AvgTimeServing)
 		}
 	}
 
@@ -0,0 +1,392 @@
+package petri
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// arc kinds used in net files
+const (
+	ArcIn        = "in"
+	ArcOut       = "out"
+	ArcInfo      = "info"
+	ArcInhibitor = "inhibitor"
+	ArcReset     = "reset"
+)
+
+// NetFile is the declarative description of a Net stored in JSON or YAML
+type NetFile struct {
+	Name        string           `json:"name" yaml:"name"`
+	Places      []PlaceFile      `json:"places" yaml:"places"`
+	Transitions []TransitionFile `json:"transitions" yaml:"transitions"`
+	Arcs        []ArcFile        `json:"arcs" yaml:"arcs"`
+
+	dir string // directory of the net file, traces are looked up there
+}
+
+type PlaceFile struct {
+	Name     string  `json:"name" yaml:"name"`
+	Mark     float64 `json:"mark" yaml:"mark"`
+	Coloured bool    `json:"coloured,omitempty" yaml:"coloured,omitempty"` // keeps token records, see Place.SetColoured
+}
+
+type TransitionFile struct {
+	Name         string   `json:"name" yaml:"name"`
+	Distribution string   `json:"distribution,omitempty" yaml:"distribution,omitempty"`
+	Mean         float64  `json:"mean" yaml:"mean"`
+	Deviation    float64  `json:"deviation,omitempty" yaml:"deviation,omitempty"`
+	Priority     int      `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Probability  *float64 `json:"probability,omitempty" yaml:"probability,omitempty"` // 1 if omitted
+
+	Params map[string]float64 `json:"params,omitempty" yaml:"params,omitempty"`
+	Trace  *TraceFile         `json:"trace,omitempty" yaml:"trace,omitempty"` // replaces the distribution
+}
+
+// TraceFile points to a CSV with recorded delays, see ReadTrace
+type TraceFile struct {
+	Path       string `json:"path" yaml:"path" xml:"path,attr"` // relative to the net file
+	Column     int    `json:"column,omitempty" yaml:"column,omitempty" xml:"column,attr,omitempty"`
+	Timestamps bool   `json:"timestamps,omitempty" yaml:"timestamps,omitempty" xml:"timestamps,attr,omitempty"`
+	Cycle      bool   `json:"cycle,omitempty" yaml:"cycle,omitempty" xml:"cycle,attr,omitempty"`
+}
+
+type ArcFile struct {
+	Place      string `json:"place" yaml:"place"`
+	Transition string `json:"transition" yaml:"transition"`
+	Kind       string `json:"kind" yaml:"kind"`                         // in, out, info, inhibitor, reset
+	Weight     int    `json:"weight,omitempty" yaml:"weight,omitempty"` // 1 if omitted
+	Class      string `json:"class,omitempty" yaml:"class,omitempty"`   // token class of a coloured place
+}
+
+type BuildNetFile interface {
+	Build() (Net, error)
+	FromNet(*Net) *NetFile
+}
+
+func (f *NetFile) Build() (Net, error) {
+	var places []*Place
+	var transitions []*Transition
+	var linksIn []*Linker
+	var linksOut []*Linker
+	var counter GlobalCounter
+
+	placeIndex := make(map[string]int)
+	for i, p := range f.Places {
+		if p.Name == "" {
+			return Net{}, fmt.Errorf("net %s: place #%d has no name", f.Name, i)
+		}
+
+		if _, ok := placeIndex[p.Name]; ok {
+			return Net{}, fmt.Errorf("net %s: duplicate place %s", f.Name, p.Name)
+		}
+
+		if p.Mark < 0 {
+			return Net{}, fmt.Errorf("net %s: place %s has negative mark %f", f.Name, p.Name, p.Mark)
+		}
+
+		placeIndex[p.Name] = i
+		place := (&Place{}).Build(p.Name, p.Mark, &counter)
+		if p.Coloured {
+			place.SetColoured(true)
+		}
+		places = append(places, place)
+	}
+
+	transitionIndex := make(map[string]int)
+	for i, t := range f.Transitions {
+		if t.Name == "" {
+			return Net{}, fmt.Errorf("net %s: transition #%d has no name", f.Name, i)
+		}
+
+		if _, ok := transitionIndex[t.Name]; ok {
+			return Net{}, fmt.Errorf("net %s: duplicate transition %s", f.Name, t.Name)
+		}
+
+		if t.Mean < 0 || t.Deviation < 0 {
+			return Net{}, fmt.Errorf("net %s: transition %s has negative time parameters", f.Name, t.Name)
+		}
+
+		probability := 1.0
+		if t.Probability != nil {
+			probability = *t.Probability
+		}
+
+		if probability < 0 {
+			return Net{}, fmt.Errorf("net %s: transition %s has negative probability %f", f.Name, t.Name, probability)
+		}
+
+		transitionIndex[t.Name] = i
+		transition := (&Transition{}).Build(t.Name, t.Mean, probability, &counter)
+		transition.SetDistribution(t.Distribution, t.Mean)
+		transition.SetDeviation(t.Deviation)
+		transition.SetPriority(t.Priority)
+		for k, v := range t.Params {
+			transition.SetParam(k, v)
+		}
+
+		if t.Trace != nil {
+			path := t.Trace.Path
+			if !filepath.IsAbs(path) && f.dir != "" {
+				path = filepath.Join(f.dir, path)
+			}
+
+			trace, err := LoadTrace(path, t.Trace.Column, t.Trace.Timestamps)
+			if err != nil {
+				return Net{}, fmt.Errorf("net %s: transition %s: %v", f.Name, t.Name, err)
+			}
+
+			trace.Cycle = t.Trace.Cycle
+			transition.SetDelay(trace)
+		}
+		transitions = append(transitions, transition)
+	}
+
+	for i, a := range f.Arcs {
+		p, ok := placeIndex[a.Place]
+		if !ok {
+			return Net{}, fmt.Errorf("net %s: arc #%d refers to unknown place %s", f.Name, i, a.Place)
+		}
+
+		t, ok := transitionIndex[a.Transition]
+		if !ok {
+			return Net{}, fmt.Errorf("net %s: arc #%d refers to unknown transition %s", f.Name, i, a.Transition)
+		}
+
+		weight := a.Weight
+		if weight == 0 {
+			weight = 1
+		}
+
+		if weight < 0 {
+			return Net{}, fmt.Errorf("net %s: arc #%d %s-%s has negative weight %d", f.Name, i, a.Place, a.Transition, weight)
+		}
+
+		var l *Linker
+		switch strings.ToLower(a.Kind) {
+		case ArcIn, ArcInfo, ArcInhibitor, ArcReset:
+			l = (&Linker{}).Build(places[p], transitions[t], weight, strings.ToLower(a.Kind) == ArcInfo, &counter, `i`)
+			l.SetInhibitor(strings.ToLower(a.Kind) == ArcInhibitor)
+			l.SetReset(strings.ToLower(a.Kind) == ArcReset)
+			linksIn = append(linksIn, l)
+		case ArcOut:
+			l = (&Linker{}).Build(places[p], transitions[t], weight, false, &counter, `o`)
+			linksOut = append(linksOut, l)
+		default:
+			return Net{}, fmt.Errorf("net %s: arc #%d has unknown kind %s", f.Name, i, a.Kind)
+		}
+
+		if a.Class != "" {
+			if !places[p].IsColoured() {
+				return Net{}, fmt.Errorf("net %s: arc #%d selects class %s of place %s that isn't coloured", f.Name, i, a.Class, a.Place)
+			}
+			l.SetClass(a.Class)
+		}
+	}
+
+	return (&Net{}).BuildChecked(f.Name, places, transitions, linksIn, linksOut)
+}
+
+func (f *NetFile) FromNet(n *Net) *NetFile {
+	f.Name = n.Name
+	f.Places = []PlaceFile{}
+	f.Transitions = []TransitionFile{}
+	f.Arcs = []ArcFile{}
+
+	for _, p := range n.Places {
+		f.Places = append(f.Places, PlaceFile{Name: p.Name, Mark: p.Mark, Coloured: p.Coloured})
+	}
+
+	for _, t := range n.Transitions {
+		probability := t.Probability
+		f.Transitions = append(f.Transitions, TransitionFile{
+			Name:         t.Name,
+			Distribution: t.Distribution,
+			Mean:         t.AvgTimeServing,
+			Deviation:    t.AvgDeviation,
+			Priority:     t.Priority,
+			Probability:  &probability,
+			Params:       t.Params,
+		})
+
+		if trace, ok := t.delay.(*Trace); ok && trace.Path != "" {
+			f.Transitions[len(f.Transitions)-1].Trace = &TraceFile{
+				Path:       f.tracePath(trace.Path),
+				Column:     trace.Column,
+				Timestamps: trace.Timestamps,
+				Cycle:      trace.Cycle,
+			}
+		}
+	}
+
+	for _, l := range n.LinksIn {
+		f.Arcs = append(f.Arcs, f.arc(n, l, l.Kind()))
+	}
+
+	for _, l := range n.LinksOut {
+		f.Arcs = append(f.Arcs, f.arc(n, l, ArcOut))
+	}
+
+	return f
+}
+
+// tracePath gives the path of a trace relative to the directory of the net file, traces
+// keep the path they were opened with
+func (f *NetFile) tracePath(path string) string {
+	if f.dir == "" {
+		return path
+	}
+
+	dir, err := filepath.Abs(f.dir)
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+func (f *NetFile) arc(n *Net, l *Linker, kind string) ArcFile {
+	a := ArcFile{Place: l.NamePlace, Transition: l.NameTransition, Kind: kind, Weight: l.KVariant, Class: l.Class}
+
+	// names are taken from the net itself as places could be replaced after linking
+	if l.CounterPlaces >= 0 && l.CounterPlaces < len(n.Places) {
+		a.Place = n.Places[l.CounterPlaces].Name
+	}
+
+	for _, t := range n.Transitions {
+		if t.Number == l.CounterTransitions {
+			a.Transition = t.Name
+			break
+		}
+	}
+
+	return a
+}
+
+func DecodeNetJSON(r io.Reader) (Net, error) {
+	f, err := decodeNetFileJSON(r)
+	if err != nil {
+		return Net{}, err
+	}
+
+	return f.Build()
+}
+
+func decodeNetFileJSON(r io.Reader) (*NetFile, error) {
+	var f NetFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("decode json net: %v", err)
+	}
+
+	return &f, nil
+}
+
+func DecodeNetYAML(r io.Reader) (Net, error) {
+	f, err := decodeNetFileYAML(r)
+	if err != nil {
+		return Net{}, err
+	}
+
+	return f.Build()
+}
+
+func decodeNetFileYAML(r io.Reader) (*NetFile, error) {
+	var f NetFile
+	if err := yaml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("decode yaml net: %v", err)
+	}
+
+	return &f, nil
+}
+
+func EncodeNetJSON(w io.Writer, n *Net) error {
+	return encodeNetFileJSON(w, (&NetFile{}).FromNet(n))
+}
+
+func encodeNetFileJSON(w io.Writer, f *NetFile) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+func EncodeNetYAML(w io.Writer, n *Net) error {
+	return encodeNetFileYAML(w, (&NetFile{}).FromNet(n))
+}
+
+func encodeNetFileYAML(w io.Writer, f *NetFile) error {
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// LoadNet reads a net from a .json, .yaml, .yml or .pnml file
+func LoadNet(path string) (Net, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Net{}, err
+	}
+	defer f.Close()
+
+	var nf *NetFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		nf, err = decodeNetFileJSON(f)
+	case ".yaml", ".yml":
+		nf, err = decodeNetFileYAML(f)
+	case ".pnml":
+		nf, err = decodeNetFilePNML(f)
+	default:
+		return Net{}, fmt.Errorf("unknown net file format %s", path)
+	}
+
+	if err != nil {
+		return Net{}, err
+	}
+
+	nf.dir = filepath.Dir(path)
+	return nf.Build()
+}
+
+// SaveNet writes a net to a .json, .yaml, .yml or .pnml file, paths of traces are written
+// relative to the file
+func SaveNet(path string, n *Net) error {
+	var encode func(io.Writer, *NetFile) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		encode = encodeNetFileJSON
+	case ".yaml", ".yml":
+		encode = encodeNetFileYAML
+	case ".pnml":
+		encode = encodeNetFilePNML
+	default:
+		return fmt.Errorf("unknown net file format %s", path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := encode(f, (&NetFile{dir: filepath.Dir(path)}).FromNet(n)); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -10,15 +10,19 @@ func CreateNetGenerator(timeModeling float64, timeGen float64, distribution stri
 	var linksIn []*Linker
 	var linksOut []*Linker
 
+	// start counter over
+	counter.Reset()
+
 	places = append(places,
 		(&Place{}).Build("P0", 1, counter),
 		(&Place{}).Build("P1", 0, counter),
 	)
 
 	transitions = append(transitions,
-		(&Transition{}).Build("coming", timeGen, timeModeling, counter),
+		(&Transition{}).Build("coming", timeGen, 1, counter),
 	)
 	transitions[0].SetDistribution(distribution, transitions[0].TimeServing)
+	transitions[0].SetTimeModeling(timeModeling)
 
 	linksIn = append(linksIn,
 		(&Linker{}).Build(places[0], transitions[0], 1, false, counter, `i`),
@@ -29,9 +33,7 @@ func CreateNetGenerator(timeModeling float64, timeGen float64, distribution stri
 		(&Linker{}).Build(places[1], transitions[0], 1, false, counter, `o`),
 	)
 
-	net := (&Net{}).Build("Generator supplying requirement for serving", places, transitions, linksIn, linksOut)
-	counter = &GlobalCounter{}
-	return net
+	return (&Net{}).Build("Generator supplying requirement for serving", places, transitions, linksIn, linksOut)
 }
 
 func CreateNetSMOGroup(numInGroup float64, numChannel int, timeMean float64, name string, c *GlobalCounter) Net {
@@ -40,6 +42,9 @@ func CreateNetSMOGroup(numInGroup float64, numChannel int, timeMean float64, nam
 	var linksIn []*Linker
 	var linksOut []*Linker
 
+	// start counter over
+	c.Reset()
+
 	places = append(places,
 		(&Place{}).Build("P0", 0, c),
 	)
@@ -68,12 +73,7 @@ func CreateNetSMOGroup(numInGroup float64, numChannel int, timeMean float64, nam
 		)
 	}
 
-	net := (&Net{}).Build(name, places, transitions, linksIn, linksOut)
-
-	// start counter over
-	c = &GlobalCounter{}
-
-	return net
+	return (&Net{}).Build(name, places, transitions, linksIn, linksOut)
 }
 
 func CreateNetFork(timeModeling float64, numberWay int, probabilities []float64) Net {
@@ -92,11 +92,12 @@ func CreateNetFork(timeModeling float64, numberWay int, probabilities []float64)
 
 	for i := 0; i < numberWay; i++ {
 		ti := Transition{}
-		transitions = append(transitions, ti.Build(fmt.Sprintf("choice route %d", i+1), 0, timeModeling, &counter))
+		transitions = append(transitions, ti.Build(fmt.Sprintf("choice route %d", i+1), 0, 1, &counter))
 	}
 
 	for i := 0; i < len(transitions); i++ {
 		transitions[i].SetProbability(probabilities[i])
+		transitions[i].SetTimeModeling(timeModeling)
 	}
 
 	for i := 0; i < numberWay; i++ {
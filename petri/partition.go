@@ -0,0 +1,431 @@
+package petri
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Partition splits one net into objects joined by ports. Transitions reading the same place
+// always stay together, a place goes with its readers, so only output arcs cross objects and
+// become ports. A serial pilot run measures how often every transition fires; groups with the
+// most traffic between them are merged first while the firings of a part stay under an even
+// share plus Slack, so the cut goes along places with low traffic. Guards see only the places
+// of their own object, so nets with guards can't be split.
+type Partition struct {
+	Parts int
+	Hints map[string]int // places and transitions by name kept in the part with the index
+	Pilot float64        // time of the pilot run, 1000
+	Seed  int64          // seed of the random streams of the pilot run and of the parts
+	Slack float64        // share of firings a part may carry above an even split, 0.2
+
+	Objects []*Simulator
+	Ports   []*Port
+	Rates   []float64 // expected messages per unit of time through each port
+	Rate    float64   // expected messages per unit of time between all objects
+	Loads   []float64 // expected firings per unit of time of each object
+
+	net Net
+}
+
+type BuildPartition interface {
+	Build(Net, int) *Partition
+	Split(*GlobalCounter, *GlobalTime) (*Model, error)
+}
+
+// partGroup is a set of transitions with the places they read
+type partGroup struct {
+	transitions []int
+	load        float64
+	pin         int // part the group is kept in by hints, -1 for none
+}
+
+func (p *Partition) Build(n Net, parts int) *Partition {
+	p.net = n
+	p.Parts = parts
+	if p.Pilot <= 0 {
+		p.Pilot = 1000
+	}
+	if p.Slack <= 0 {
+		p.Slack = 0.2
+	}
+
+	return p
+}
+
+// netReaders lists for every place the transitions reading it with any kind of arc
+func netReaders(n *Net) [][]int {
+	readers := make([][]int, len(n.Places))
+	for t, tr := range n.Transitions {
+		for _, group := range [][]int{tr.InPlaces, tr.InPlacesWithInfo, tr.InPlacesWithInhibitor, tr.InPlacesWithReset} {
+			for _, i := range group {
+				readers[i] = append(readers[i], t)
+			}
+		}
+	}
+
+	return readers
+}
+
+// Split builds the objects and the ports between them. The net given to Build isn't changed,
+// every object gets copies of its places and transitions.
+func (p *Partition) Split(c *GlobalCounter, gtime *GlobalTime) (*Model, error) {
+	n := &p.net
+	if p.Parts < 1 {
+		return nil, fmt.Errorf("net %s can't be split into %d parts", n.Name, p.Parts)
+	}
+	if p.Parts > 1 {
+		for _, tr := range n.Transitions {
+			if tr.Guard != nil {
+				return nil, fmt.Errorf("net %s: transition %s has a guard, it reads the whole marking and can't be split", n.Name, tr.Name)
+			}
+		}
+	}
+
+	rates := p.pilotRates()
+	readers := netReaders(n)
+
+	// transitions reading one place can't be apart, the enabling check needs all its marks
+	group := make([]int, len(n.Transitions))
+	for t := range group {
+		group[t] = t
+	}
+	var find func(int) int
+	find = func(t int) int {
+		if group[t] != t {
+			group[t] = find(group[t])
+		}
+		return group[t]
+	}
+	for _, rs := range readers {
+		for _, t := range rs {
+			group[find(t)] = find(rs[0])
+		}
+	}
+
+	home := make([]int, len(n.Places)) // transition whose group holds the place
+	for i := range n.Places {
+		home[i] = -1
+		if len(readers[i]) > 0 {
+			home[i] = readers[i][0]
+		}
+	}
+	for t, tr := range n.Transitions {
+		for _, i := range tr.OutPlaces {
+			if home[i] < 0 {
+				home[i] = t
+			}
+		}
+	}
+
+	groups := make(map[int]*partGroup)
+	var order []int
+	for t := range n.Transitions {
+		r := find(t)
+		g, ok := groups[r]
+		if !ok {
+			g = &partGroup{pin: -1}
+			groups[r] = g
+			order = append(order, r)
+		}
+		g.transitions = append(g.transitions, t)
+		g.load += rates[t]
+	}
+
+	for name, part := range p.Hints {
+		if part < 0 || part >= p.Parts {
+			return nil, fmt.Errorf("hint %s: part %d out of %d", name, part, p.Parts)
+		}
+
+		t := n.FindTransitionByName(name)
+		if t < 0 {
+			if i := n.FindPlaceByName(name); i >= 0 {
+				t = home[i]
+			}
+		}
+		if t < 0 {
+			return nil, fmt.Errorf("hint %s: net %s has no such place or transition", name, n.Name)
+		}
+
+		g := groups[find(t)]
+		if g.pin >= 0 && g.pin != part {
+			return nil, fmt.Errorf("hint %s: it shares input places with transitions kept in part %d", name, g.pin)
+		}
+		g.pin = part
+	}
+
+	var clusters []*partGroup
+	for _, r := range order {
+		clusters = append(clusters, groups[r])
+	}
+	if len(clusters) < p.Parts {
+		return nil, fmt.Errorf("net %s splits into %d parts at most", n.Name, len(clusters))
+	}
+
+	of := make([]*partGroup, len(n.Transitions))
+	for _, g := range clusters {
+		for _, t := range g.transitions {
+			of[t] = g
+		}
+	}
+
+	// traffic between two groups: firings of transitions of one putting markers into places of the other
+	traffic := func(a, b *partGroup) float64 {
+		w := 0.0
+		for t, tr := range n.Transitions {
+			if of[t] != a && of[t] != b {
+				continue
+			}
+			for _, i := range tr.OutPlaces {
+				if h := home[i]; h >= 0 && of[h] != of[t] && (of[h] == a || of[h] == b) {
+					w += rates[t]
+				}
+			}
+		}
+		return w
+	}
+
+	total := 0.0
+	for _, g := range clusters {
+		total += g.load
+	}
+	limit := (1 + p.Slack) * total / float64(p.Parts)
+
+	for len(clusters) > p.Parts {
+		a, b := -1, -1
+		best, bestLoad := -1.0, math.Inf(1)
+		fits := false
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				x, y := clusters[i], clusters[j]
+				if x.pin >= 0 && y.pin >= 0 && x.pin != y.pin {
+					continue
+				}
+
+				// the heaviest traffic within the limit, else the lightest pair
+				load := x.load + y.load
+				w := traffic(x, y)
+				ok := load <= limit && w > 0
+				better := false
+				switch {
+				case ok && !fits:
+					better = true
+				case ok:
+					better = w > best || w == best && load < bestLoad
+				case !fits:
+					better = load < bestLoad
+				}
+
+				if better {
+					a, b, best, bestLoad, fits = i, j, w, load, ok
+				}
+			}
+		}
+
+		if a < 0 {
+			return nil, fmt.Errorf("net %s: hints keep %d groups apart, more than %d parts", n.Name, len(clusters), p.Parts)
+		}
+
+		x, y := clusters[a], clusters[b]
+		x.transitions = append(x.transitions, y.transitions...)
+		sort.Ints(x.transitions)
+		x.load += y.load
+		if x.pin < 0 {
+			x.pin = y.pin
+		}
+		for _, t := range y.transitions {
+			of[t] = x
+		}
+		clusters = append(clusters[:b], clusters[b+1:]...)
+	}
+
+	// pinned groups take their parts, the others fill the rest in the order of the net
+	parts := make([]*partGroup, p.Parts)
+	for _, g := range clusters {
+		if g.pin >= 0 {
+			parts[g.pin] = g
+		}
+	}
+	k := 0
+	for _, g := range clusters {
+		if g.pin >= 0 {
+			continue
+		}
+		for parts[k] != nil {
+			k++
+		}
+		parts[k] = g
+	}
+
+	return p.build(parts, of, home, rates, c, gtime)
+}
+
+// build makes an object of every part and a port of every output arc into a place of another part.
+// The transition of such an arc feeds an external stand-in of the place in its own object, the port
+// takes the tokens from there. Every part gets own random streams and own copies of traces.
+func (p *Partition) build(parts []*partGroup, of []*partGroup, home []int, rates []float64, c *GlobalCounter, gtime *GlobalTime) (*Model, error) {
+	n := &p.net
+	part := make(map[*partGroup]int)
+	for k, g := range parts {
+		part[g] = k
+	}
+
+	placePart := make([]int, len(n.Places))
+	for i := range n.Places {
+		placePart[i] = 0
+		if home[i] >= 0 {
+			placePart[i] = part[of[home[i]]]
+		}
+	}
+
+	placeIndex := make([]int, len(n.Places))
+	transitionIndex := make([]int, len(n.Transitions))
+	p.Objects = nil
+	p.Ports = nil
+	p.Rates = nil
+	p.Rate = 0
+	p.Loads = make([]float64, len(parts))
+	streams := &Streams{Seed: p.Seed}
+
+	type crossing struct {
+		transition, place, standIn, weight int
+	}
+	var crossings []crossing
+
+	for k, g := range parts {
+		var places []*Place
+		for i, pl := range n.Places {
+			if placePart[i] == k {
+				placeIndex[i] = len(places)
+				clone := pl.Clone().(*Place)
+				clone.SetNumber(len(places))
+				places = append(places, clone)
+			}
+		}
+
+		var transitions []*Transition
+		for _, t := range g.transitions {
+			transitionIndex[t] = len(transitions)
+			clone := n.Transitions[t].Clone().(*Transition)
+			clone.SetNumber(len(transitions))
+			clone.SetRandom(streams.Stream("part", k, "transition", clone.Name))
+			if tr, ok := clone.delay.(*Trace); ok {
+				clone.delay = tr.clone()
+			}
+			transitions = append(transitions, clone)
+			p.Loads[k] += rates[t]
+		}
+
+		var linksIn, linksOut []*Linker
+		for _, l := range n.LinksIn {
+			if of[l.CounterTransitions] == g {
+				clone := l.Clone().(*Linker)
+				clone.CounterPlaces = placeIndex[l.CounterPlaces]
+				clone.CounterTransitions = transitionIndex[l.CounterTransitions]
+				linksIn = append(linksIn, clone)
+			}
+		}
+		standIns := make(map[int]int)
+		for _, l := range n.LinksOut {
+			if of[l.CounterTransitions] != g {
+				continue
+			}
+
+			clone := l.Clone().(*Linker)
+			clone.CounterPlaces = placeIndex[l.CounterPlaces]
+			clone.CounterTransitions = transitionIndex[l.CounterTransitions]
+
+			if placePart[l.CounterPlaces] != k {
+				q, ok := standIns[l.CounterPlaces]
+				if !ok {
+					q = len(places)
+					standIns[l.CounterPlaces] = q
+					places = append(places, standIn(n.Places[l.CounterPlaces], q))
+				}
+				clone.CounterPlaces = q
+				crossings = append(crossings, crossing{l.CounterTransitions, l.CounterPlaces, q, l.KVariant})
+			}
+
+			linksOut = append(linksOut, clone)
+		}
+
+		net := (&Net{}).Build(fmt.Sprintf("%s_%d", n.Name, k), places, transitions, linksIn, linksOut)
+		obj := (&Simulator{}).Build(net, c, gtime, nil, nil)
+		obj.Random = streams.Stream("part", k)
+		p.Objects = append(p.Objects, obj)
+	}
+
+	for _, x := range crossings {
+		from := p.Objects[part[of[x.transition]]]
+		port, err := from.connect(transitionIndex[x.transition], p.Objects[placePart[x.place]], placeIndex[x.place])
+		if err != nil {
+			return nil, err
+		}
+
+		port.source = x.standIn
+		port.Count = x.weight
+		p.Ports = append(p.Ports, port)
+		p.Rates = append(p.Rates, rates[x.transition])
+		p.Rate += rates[x.transition]
+	}
+
+	return (&Model{}).Build(p.Objects, gtime), nil
+}
+
+// standIn is an empty external copy of a place of another part, a coloured one keeps
+// the tokens routed to it until the port takes them
+func standIn(pl *Place, number int) *Place {
+	s := pl.Clone().(*Place)
+	s.SetNumber(number)
+	s.SetMark(0)
+	s.Tokens = nil
+	s.SetExternal(true)
+	if s.IsColoured() {
+		s.inbox = &tokenInbox{}
+	}
+
+	return s
+}
+
+// pilotRates runs a copy of the net alone and returns the firings per unit of time of every transition.
+// The copy leaves the net as it was: hooks aren't called, random numbers come from own streams and
+// stateful delays like traces are set back afterwards.
+func (p *Partition) pilotRates() []float64 {
+	n := &p.net
+	fired := make([]float64, len(n.Transitions))
+
+	var places []*Place
+	for _, pl := range n.Places {
+		places = append(places, pl.Clone().(*Place))
+	}
+
+	var transitions []*Transition
+	for t, tr := range n.Transitions {
+		clone := tr.Clone().(*Transition)
+		t := t
+		clone.ActInHooks = nil
+		clone.ActOutHooks = []FireHook{func(*Transition, []*Place, float64) {
+			fired[t]++
+		}}
+		transitions = append(transitions, clone)
+
+		if d, ok := tr.delay.(StatefulDistribution); ok {
+			defer d.SetState(d.State())
+		}
+	}
+
+	var c GlobalCounter
+	var gtime GlobalTime
+	net := (&Net{}).Build(n.Name, places, transitions, n.LinksIn, n.LinksOut)
+	pilot := (&Model{}).Build([]*Simulator{(&Simulator{}).Build(net, &c, &gtime, nil, nil)}, &gtime)
+	pilot.IsProtocolPrint = false
+	pilot.SetSeed(p.Seed)
+	pilot.GoRun(p.Pilot)
+
+	rates := make([]float64, len(fired))
+	for t, f := range fired {
+		rates[t] = f / p.Pilot
+	}
+
+	return rates
+}
@@ -15,6 +15,17 @@ type Place struct {
 	ObservedMin float64
 
 	External bool
+
+	// coloured places keep a record per marker, see SetColoured
+	Coloured bool
+	Tokens   []*Token
+	Sojourn  Tally // time tokens stayed in the place
+	Age      Tally // time since creation of tokens arriving at the place
+
+	inbox *tokenInbox
+	ids   *tokenCounter // numbers new tokens, shared by the places of a net or a model
+
+	dependents []*Transition // transitions whose condition reads the place
 }
 
 type BuildPlace interface {
@@ -30,6 +41,7 @@ type BuildPlace interface {
 
 	GetObservedMax() float64
 	GetObservedMin() float64
+	ResetStatistics() BuildPlace
 
 	GetName() string
 	SetName(string) BuildPlace
@@ -40,6 +52,11 @@ type BuildPlace interface {
 	InitNext(*GlobalCounter) BuildPlace
 	IsExternal() bool
 	SetExternal(bool) BuildPlace
+	IsColoured() bool
+	SetColoured(bool) BuildPlace
+	CountTokens(string) int
+	PutTokens([]*Token, float64)
+	TakeTokens(int, string, float64) []*Token
 
 	Print()
 
@@ -53,7 +70,6 @@ func (p *Place) Build(name string, mark float64, c *GlobalCounter) *Place {
 	p.Counter = c
 	p.initNumber()
 	p.incr()
-	p.Number = p.Counter.Place
 	p.ObservedMax = mark
 	p.ObservedMin = mark
 	return p
@@ -86,11 +102,13 @@ func (p *Place) GetMark() float64 {
 
 func (p *Place) SetMark(m float64) BuildPlace {
 	p.Mark = m
+	p.changed()
 	return p
 }
 
 func (p *Place) IncrMark(m float64) {
 	p.Mark += m
+	p.changed()
 	if p.ObservedMax < p.Mark {
 		p.ObservedMax = p.Mark
 	}
@@ -102,6 +120,7 @@ func (p *Place) IncrMark(m float64) {
 
 func (p *Place) DecrMark(m float64) {
 	p.Mark -= m
+	p.changed()
 	if p.ObservedMax < p.Mark {
 		p.ObservedMax = p.Mark
 	}
@@ -111,6 +130,16 @@ func (p *Place) DecrMark(m float64) {
 	}
 }
 
+// ResetStatistics starts the mean, the observed range and the tallies of tokens over from now
+func (p *Place) ResetStatistics() BuildPlace {
+	p.Mean = 0
+	p.ObservedMax = p.Mark
+	p.ObservedMin = p.Mark
+	p.Sojourn.Reset()
+	p.Age.Reset()
+	return p
+}
+
 func (p *Place) GetObservedMax() float64 {
 	return p.ObservedMax
 }
@@ -158,5 +187,7 @@ func (p *Place) Print() {
 func (p *Place) Clone() BuildPlace {
 	var n Place
 	n = *p
+	n.Tokens = append([]*Token(nil), p.Tokens...)
+	n.dependents = nil
 	return &n
 }
@@ -0,0 +1,370 @@
+package petri
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	PNMLNamespace = "http://www.pnml.org/version-2009/grammar/pnml"
+	PNMLNetType   = "http://www.pnml.org/version-2009/grammar/ptnet"
+	PNMLTool      = "parallel-testing"
+	PNMLVersion   = "1.0"
+)
+
+type pnmlDocument struct {
+	XMLName xml.Name  `xml:"pnml"`
+	Xmlns   string    `xml:"xmlns,attr,omitempty"`
+	Nets    []pnmlNet `xml:"net"`
+}
+
+type pnmlNet struct {
+	ID    string     `xml:"id,attr"`
+	Type  string     `xml:"type,attr"`
+	Name  *pnmlText  `xml:"name"`
+	Pages []pnmlPage `xml:"page"`
+}
+
+type pnmlPage struct {
+	ID                   string           `xml:"id,attr"`
+	Places               []pnmlPlace      `xml:"place"`
+	Transitions          []pnmlTransition `xml:"transition"`
+	Arcs                 []pnmlArc        `xml:"arc"`
+	ReferencePlaces      []pnmlReference  `xml:"referencePlace"`
+	ReferenceTransitions []pnmlReference  `xml:"referenceTransition"`
+	Pages                []pnmlPage       `xml:"page"`
+}
+
+type pnmlText struct {
+	Text string `xml:"text"`
+}
+
+type pnmlPlace struct {
+	ID             string             `xml:"id,attr"`
+	Name           *pnmlText          `xml:"name"`
+	InitialMarking *pnmlText          `xml:"initialMarking"`
+	ToolSpecific   []pnmlToolSpecific `xml:"toolspecific"`
+}
+
+type pnmlTransition struct {
+	ID           string             `xml:"id,attr"`
+	Name         *pnmlText          `xml:"name"`
+	ToolSpecific []pnmlToolSpecific `xml:"toolspecific"`
+}
+
+type pnmlArc struct {
+	ID           string             `xml:"id,attr"`
+	Source       string             `xml:"source,attr"`
+	Target       string             `xml:"target,attr"`
+	Inscription  *pnmlText          `xml:"inscription"`
+	ToolSpecific []pnmlToolSpecific `xml:"toolspecific"`
+}
+
+type pnmlReference struct {
+	ID  string `xml:"id,attr"`
+	Ref string `xml:"ref,attr"`
+}
+
+// timing and arc kinds of this tool, other tools keep them as opaque extensions
+type pnmlToolSpecific struct {
+	Tool    string       `xml:"tool,attr"`
+	Version string       `xml:"version,attr"`
+	Timing  *pnmlTiming  `xml:"timing"`
+	Arc     *pnmlArcKind `xml:"arc"`
+	Tokens  *pnmlTokens  `xml:"tokens"`
+}
+
+type pnmlTiming struct {
+	Distribution string  `xml:"distribution,attr,omitempty"`
+	Mean         float64 `xml:"mean,attr"`
+	Deviation    float64 `xml:"deviation,attr,omitempty"`
+	Priority     int     `xml:"priority,attr,omitempty"`
+	Probability  float64 `xml:"probability,attr"`
+
+	Params []pnmlParam `xml:"param"`
+	Trace  *TraceFile  `xml:"trace"`
+}
+
+type pnmlParam struct {
+	Name  string  `xml:"name,attr"`
+	Value float64 `xml:"value,attr"`
+}
+
+type pnmlArcKind struct {
+	Kind  string `xml:"kind,attr"`
+	Class string `xml:"class,attr,omitempty"`
+}
+
+type pnmlTokens struct {
+	Coloured bool `xml:"coloured,attr"`
+}
+
+func (t *pnmlText) value() string {
+	if t == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(t.Text)
+}
+
+func DecodeNetPNML(r io.Reader) (Net, error) {
+	f, err := decodeNetFilePNML(r)
+	if err != nil {
+		return Net{}, err
+	}
+
+	return f.Build()
+}
+
+func decodeNetFilePNML(r io.Reader) (*NetFile, error) {
+	var doc pnmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode pnml net: %v", err)
+	}
+
+	if len(doc.Nets) != 1 {
+		return nil, fmt.Errorf("decode pnml net: document has %d nets, want one", len(doc.Nets))
+	}
+
+	return doc.Nets[0].netFile()
+}
+
+func (n *pnmlNet) netFile() (*NetFile, error) {
+	f := &NetFile{Name: n.Name.value()}
+	if f.Name == "" {
+		f.Name = n.ID
+	}
+
+	names := make(map[string]string)
+	places := make(map[string]bool)
+	transitions := make(map[string]bool)
+	placeNames := make(map[string]bool)
+	transitionNames := make(map[string]bool)
+	refs := make(map[string]string)
+
+	// PNML names are not unique, ids are; places and transitions have own names
+	unique := func(used map[string]bool, id string, name string) string {
+		if name == "" || used[name] {
+			name = id
+		}
+
+		used[name] = true
+		names[id] = name
+		return name
+	}
+
+	var arcs []pnmlArc
+	var walk func(pages []pnmlPage) error
+	walk = func(pages []pnmlPage) error {
+		for _, page := range pages {
+			for _, p := range page.Places {
+				mark := 0.0
+				if v := p.InitialMarking.value(); v != "" {
+					m, err := strconv.ParseFloat(v, 64)
+					if err != nil {
+						return fmt.Errorf("pnml place %s: bad initial marking %q", p.ID, v)
+					}
+					mark = m
+				}
+
+				pf := PlaceFile{Name: unique(placeNames, p.ID, p.Name.value()), Mark: mark}
+				for _, ts := range p.ToolSpecific {
+					if ts.Tool == PNMLTool && ts.Tokens != nil {
+						pf.Coloured = ts.Tokens.Coloured
+					}
+				}
+				places[p.ID] = true
+				f.Places = append(f.Places, pf)
+			}
+
+			for _, t := range page.Transitions {
+				tf := TransitionFile{Name: unique(transitionNames, t.ID, t.Name.value())}
+				for _, ts := range t.ToolSpecific {
+					if ts.Tool == PNMLTool && ts.Timing != nil {
+						probability := ts.Timing.Probability
+						tf.Distribution = ts.Timing.Distribution
+						tf.Mean = ts.Timing.Mean
+						tf.Deviation = ts.Timing.Deviation
+						tf.Priority = ts.Timing.Priority
+						tf.Probability = &probability
+						tf.Trace = ts.Timing.Trace
+						for _, p := range ts.Timing.Params {
+							if tf.Params == nil {
+								tf.Params = make(map[string]float64)
+							}
+							tf.Params[p.Name] = p.Value
+						}
+					}
+				}
+
+				transitions[t.ID] = true
+				f.Transitions = append(f.Transitions, tf)
+			}
+
+			for _, ref := range page.ReferencePlaces {
+				refs[ref.ID] = ref.Ref
+			}
+
+			for _, ref := range page.ReferenceTransitions {
+				refs[ref.ID] = ref.Ref
+			}
+
+			arcs = append(arcs, page.Arcs...)
+			if err := walk(page.Pages); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(n.Pages); err != nil {
+		return nil, err
+	}
+
+	resolve := func(id string) string {
+		for i := 0; i < len(refs); i++ {
+			ref, ok := refs[id]
+			if !ok {
+				break
+			}
+			id = ref
+		}
+
+		return id
+	}
+
+	for _, a := range arcs {
+		source, target := resolve(a.Source), resolve(a.Target)
+
+		weight := 1
+		if v := a.Inscription.value(); v != "" {
+			w, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("pnml arc %s: bad inscription %q", a.ID, v)
+			}
+			weight = w
+		}
+
+		var arc ArcFile
+		if places[source] && transitions[target] {
+			arc = ArcFile{Place: names[source], Transition: names[target], Kind: ArcIn, Weight: weight}
+		} else if transitions[source] && places[target] {
+			arc = ArcFile{Place: names[target], Transition: names[source], Kind: ArcOut, Weight: weight}
+		} else {
+			return nil, fmt.Errorf("pnml arc %s: %s and %s aren't a place and a transition", a.ID, a.Source, a.Target)
+		}
+
+		for _, ts := range a.ToolSpecific {
+			if ts.Tool == PNMLTool && ts.Arc != nil {
+				arc.Kind = ts.Arc.Kind
+				arc.Class = ts.Arc.Class
+			}
+		}
+
+		f.Arcs = append(f.Arcs, arc)
+	}
+
+	return f, nil
+}
+
+func EncodeNetPNML(w io.Writer, n *Net) error {
+	return encodeNetFilePNML(w, (&NetFile{}).FromNet(n))
+}
+
+func encodeNetFilePNML(w io.Writer, f *NetFile) error {
+	page := pnmlPage{ID: "page0"}
+	placeIDs := make(map[string]string)
+	transitionIDs := make(map[string]string)
+
+	for i, p := range f.Places {
+		id := fmt.Sprintf("p%d", i)
+		placeIDs[p.Name] = id
+		place := pnmlPlace{
+			ID:             id,
+			Name:           &pnmlText{Text: p.Name},
+			InitialMarking: &pnmlText{Text: strconv.FormatFloat(p.Mark, 'f', -1, 64)},
+		}
+		if p.Coloured {
+			place.ToolSpecific = []pnmlToolSpecific{{Tool: PNMLTool, Version: PNMLVersion, Tokens: &pnmlTokens{Coloured: true}}}
+		}
+		page.Places = append(page.Places, place)
+	}
+
+	for i, t := range f.Transitions {
+		id := fmt.Sprintf("t%d", i)
+		transitionIDs[t.Name] = id
+
+		var params []pnmlParam
+		for name, v := range t.Params {
+			params = append(params, pnmlParam{Name: name, Value: v})
+		}
+		sort.Slice(params, func(i, j int) bool {
+			return params[i].Name < params[j].Name
+		})
+
+		page.Transitions = append(page.Transitions, pnmlTransition{
+			ID:   id,
+			Name: &pnmlText{Text: t.Name},
+			ToolSpecific: []pnmlToolSpecific{{
+				Tool:    PNMLTool,
+				Version: PNMLVersion,
+				Timing: &pnmlTiming{
+					Distribution: t.Distribution,
+					Mean:         t.Mean,
+					Deviation:    t.Deviation,
+					Priority:     t.Priority,
+					Probability:  *t.Probability,
+					Params:       params,
+					Trace:        t.Trace,
+				},
+			}},
+		})
+	}
+
+	for i, a := range f.Arcs {
+		arc := pnmlArc{
+			ID:          fmt.Sprintf("a%d", i),
+			Inscription: &pnmlText{Text: strconv.Itoa(a.Weight)},
+		}
+
+		if a.Kind == ArcOut {
+			arc.Source, arc.Target = transitionIDs[a.Transition], placeIDs[a.Place]
+		} else {
+			arc.Source, arc.Target = placeIDs[a.Place], transitionIDs[a.Transition]
+		}
+
+		if (a.Kind != ArcIn && a.Kind != ArcOut) || a.Class != "" {
+			arc.ToolSpecific = []pnmlToolSpecific{{Tool: PNMLTool, Version: PNMLVersion, Arc: &pnmlArcKind{Kind: a.Kind, Class: a.Class}}}
+		}
+
+		page.Arcs = append(page.Arcs, arc)
+	}
+
+	doc := pnmlDocument{
+		Xmlns: PNMLNamespace,
+		Nets: []pnmlNet{{
+			ID:    "net0",
+			Type:  PNMLNetType,
+			Name:  &pnmlText{Text: f.Name},
+			Pages: []pnmlPage{page},
+		}},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
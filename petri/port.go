@@ -0,0 +1,548 @@
+package petri
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Port passes markers from an output transition of one object to an input place of another
+// one. Every port has its own mailbox of messages: firings with the number of markers they
+// put into the place, promises that the transition won't fire before a time and the end.
+type Port struct {
+	From       *Simulator
+	Transition int // index in From.Transitions
+	To         *Simulator
+	Place      int // index in To.Places
+	Count      int // markers a firing puts into the place
+
+	// a firing ends not sooner than this after the object may start it. Connect takes
+	// Transition.MinDelay, set it after connecting when delays are known to be longer,
+	// e.g. exp delays give none and objects then move on only by deadlock recovery.
+	Lookahead float64
+
+	Mailbox *Mailbox
+
+	source  int       // index in From.Places of the place the transition fed before it was linked, -1 for none
+	sent    float64   // sender side: no firing will be sent before this time
+	clock   float64   // receiver side: no firing with a smaller time will come
+	pending []Message // receiver side: firings not delivered yet
+}
+
+// portGroup holds objects joined by ports, when all of them wait for each other the
+// earliest event of all is safe
+type portGroup struct {
+	sync.Mutex
+	members    []*portMember
+	recoveries int64 // deadlocks broken
+}
+
+type portMember struct {
+	obj     *Simulator
+	end     float64 // the object runs until this time
+	floor   float64 // no event comes before it, raised by deadlock recovery
+	next    float64 // earliest own event while blocked
+	blocked bool
+	done    bool
+}
+
+// Connect adds a port from the transition of the object to a place of another one. A place
+// the two objects share becomes external for the object, the transition puts its markers into
+// the own copy of the other object; otherwise every firing adds one marker there.
+func (s *Simulator) Connect(transition string, to *Simulator, place string) (*Port, error) {
+	t := s.TNet.FindTransitionByName(transition)
+	if t < 0 {
+		return nil, fmt.Errorf("object %s has no transition %s", s.Name, transition)
+	}
+
+	q := to.TNet.FindPlaceByName(place)
+	if q < 0 {
+		return nil, fmt.Errorf("object %s has no place %s", to.Name, place)
+	}
+
+	return s.connect(t, to, q)
+}
+
+func (s *Simulator) connect(t int, to *Simulator, q int) (*Port, error) {
+	if s == to {
+		return nil, fmt.Errorf("object %s can't be linked to itself", s.Name)
+	}
+
+	tr := s.Transitions[t]
+	port := &Port{From: s, Transition: t, To: to, Place: q, Count: 1, Lookahead: tr.MinDelay(), source: s.placeIndex(to.Places[q])}
+	for _, p := range s.OutPorts {
+		if p.To == to && p.Place == q && p.source >= 0 {
+			port.source = p.source
+		}
+	}
+
+	if port.source >= 0 {
+		for _, other := range s.Transitions {
+			for _, group := range [][]int{other.InPlaces, other.InPlacesWithInfo, other.InPlacesWithInhibitor, other.InPlacesWithReset} {
+				for _, i := range group {
+					if i == port.source {
+						return nil, fmt.Errorf("place %s of object %s is read by transition %s, it can't feed a port",
+							s.Places[i].Name, s.Name, other.Name)
+					}
+				}
+			}
+		}
+
+		for i, out := range tr.OutPlaces {
+			if out == port.source {
+				port.Count = tr.CounterOutPlaces[i]
+			}
+		}
+	}
+
+	// objects run in own goroutines and can't share the place
+	if port.source >= 0 && s.Places[port.source] == to.Places[q] {
+		shared := s.Places[port.source]
+		to.Places[q] = shared.Clone().(*Place)
+		for i, sp := range to.StatisticsPlaces {
+			if sp == shared {
+				to.StatisticsPlaces[i] = to.Places[q]
+			}
+		}
+		to.enabling.relink()
+
+		shared.SetMark(0)
+		shared.Tokens = nil
+		shared.SetExternal(true)
+	}
+
+	port.Mailbox = newMailbox(s.Limit)
+	s.OutPorts = append(s.OutPorts, port)
+	to.InPorts = append(to.InPorts, port)
+	s.join(to)
+
+	return port, nil
+}
+
+// join puts the objects into one group
+func (s *Simulator) join(to *Simulator) {
+	for _, obj := range []*Simulator{s, to} {
+		if obj.group == nil {
+			obj.group = &portGroup{members: []*portMember{{obj: obj}}}
+		}
+	}
+
+	if s.group == to.group {
+		return
+	}
+
+	g := to.group
+	for _, m := range g.members {
+		m.obj.group = s.group
+	}
+	s.group.members = append(s.group.members, g.members...)
+}
+
+func (g *portGroup) member(s *Simulator) *portMember {
+	for _, m := range g.members {
+		if m.obj == s {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// ConnectShared adds ports between objects sharing places, like GetModelSMOGroupForTestParallel
+// builds them: every transition putting markers into the place gets a port to the object
+// with a transition taking them
+func (m *Model) ConnectShared() error {
+	owner := make(map[*Place]int)
+	for i, s := range m.Objects {
+		for k, p := range s.Places {
+			j, ok := owner[p]
+			if !ok {
+				owner[p] = i
+				continue
+			}
+
+			a, b := m.Objects[j], s
+			pa, pb := a.placeIndex(p), k
+			switch {
+			case a.feeds(pa) && !b.feeds(pb):
+			case b.feeds(pb) && !a.feeds(pa):
+				a, b, pa, pb = b, a, pb, pa
+			default:
+				return fmt.Errorf("objects %s and %s share place %s, markers must go one way", a.Name, b.Name, p.Name)
+			}
+
+			var feeders []int
+			for t, tr := range a.Transitions {
+				for _, out := range tr.OutPlaces {
+					if out == pa {
+						feeders = append(feeders, t)
+						break
+					}
+				}
+			}
+
+			for _, t := range feeders {
+				if _, err := a.connect(t, b, pb); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Simulator) placeIndex(p *Place) int {
+	for i, q := range s.Places {
+		if q == p {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// feeds tells whether a transition of the object puts markers into the place
+func (s *Simulator) feeds(p int) bool {
+	for _, t := range s.Transitions {
+		for _, i := range t.OutPlaces {
+			if i == p {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// sharedPlace tells which objects share a place, changes of a shared place touch
+// the enabling sets of all its readers, so such objects can't step at once
+func sharedPlace(objects []*Simulator) error {
+	owner := make(map[*Place]*Simulator)
+	for _, s := range objects {
+		for _, p := range s.Places {
+			if other, ok := owner[p]; ok && other != s {
+				return fmt.Errorf("objects %s and %s share place %s, join them with ports, see Model.ConnectShared",
+					other.Name, s.Name, p.Name)
+			}
+			owner[p] = s
+		}
+	}
+
+	return nil
+}
+
+// portsOf lists the ports between the objects. Objects running in parallel can't share
+// places and every port has to join two of them, else the run would race or wait forever.
+func portsOf(objects []*Simulator) ([]*Port, error) {
+	if err := sharedPlace(objects); err != nil {
+		return nil, err
+	}
+
+	running := make(map[*Simulator]bool)
+	for _, s := range objects {
+		running[s] = true
+	}
+
+	var ports []*Port
+	for _, s := range objects {
+		for _, p := range s.InPorts {
+			if !running[p.From] {
+				return nil, fmt.Errorf("object %s gets markers from %s, it doesn't run with it", s.Name, p.From.Name)
+			}
+		}
+
+		for _, p := range s.OutPorts {
+			if !running[p.To] {
+				return nil, fmt.Errorf("object %s puts markers into %s, it doesn't run with it", s.Name, p.To.Name)
+			}
+			ports = append(ports, p)
+		}
+	}
+
+	return ports, nil
+}
+
+// startRun prepares the ports for a run of the object until the time
+func (s *Simulator) startRun(end float64) *portMember {
+	for _, p := range s.InPorts {
+		p.clock = 0
+		p.pending = nil
+	}
+
+	for _, p := range s.OutPorts {
+		p.sent = 0
+	}
+
+	if s.group == nil {
+		return nil
+	}
+
+	s.group.Lock()
+	defer s.group.Unlock()
+	m := s.group.member(s)
+	m.end = end
+	m.floor = math.Inf(-1)
+	m.blocked = false
+	m.done = false
+	return m
+}
+
+// takeMessages moves what came through the input ports to the pending firings
+func (s *Simulator) takeMessages() {
+	for _, p := range s.InPorts {
+		for m, ok := p.Mailbox.take(); ok; m, ok = p.Mailbox.take() {
+			switch m.Kind {
+			case TokenArrival:
+				p.pending = append(p.pending, m)
+				p.clock = math.Max(p.clock, m.Time)
+			case NullMessage:
+				p.clock = math.Max(p.clock, m.Time)
+			case EndOfSimulation:
+				p.clock = math.Inf(1)
+			}
+		}
+	}
+}
+
+// nextPortEvent is the earliest end of service or arrival of markers
+func (s *Simulator) nextPortEvent() float64 {
+	next := s.Calendar.NextTime()
+	for _, p := range s.InPorts {
+		if len(p.pending) > 0 && p.pending[0].Time < next {
+			next = p.pending[0].Time
+		}
+	}
+
+	return next
+}
+
+// safeTime is the time up to which no markers can come from other objects
+func (s *Simulator) safeTime(m *portMember) float64 {
+	safe := math.Inf(1)
+	for _, p := range s.InPorts {
+		safe = math.Min(safe, p.clock)
+	}
+
+	if m == nil {
+		return safe
+	}
+
+	s.group.Lock()
+	defer s.group.Unlock()
+	return math.Max(safe, m.floor)
+}
+
+// handlePortEvent delivers the markers due at the time, puts out the markers of transitions
+// and sends the firings through the ports
+func (s *Simulator) handlePortEvent(t float64) error {
+	if t > s.TimeLocal {
+		s.MoveTimeLocal(t)
+	}
+	now := s.TimeLocal
+
+	for _, p := range s.InPorts {
+		for len(p.pending) > 0 && p.pending[0].Time <= now {
+			p.pending[0].put(s.Places[p.Place], now)
+			p.pending = p.pending[1:]
+		}
+	}
+
+	for tr := s.Calendar.Next(); tr != nil && tr.MinTime <= now; tr = s.Calendar.Next() {
+		tr.ActOut(s.Places)
+		for _, p := range s.OutPorts {
+			if s.Transitions[p.Transition] == tr {
+				if now < p.sent {
+					log.Printf("port %s -> %s: firing at %f before %f, the lookahead %f is too long",
+						s.Name, p.To.Name, now, p.sent, p.Lookahead)
+				}
+				p.sent = math.Max(p.sent, now)
+				m := Message{Kind: TokenArrival, Time: now, Count: p.Count}
+				if p.source >= 0 {
+					m.Tokens = s.Places[p.source].takeSent(p.Count)
+				}
+				if err := s.post(p, m); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	s.Input()
+	return nil
+}
+
+// sendPromises tells the fed objects that no firing comes before the object can fire again:
+// a firing that starts after safe ends a lookahead later, a running one ends as scheduled
+func (s *Simulator) sendPromises(safe float64) error {
+	for _, p := range s.OutPorts {
+		bound := math.Min(safe+p.Lookahead, s.Transitions[p.Transition].MinTime)
+		bound = math.Max(bound, s.TimeLocal)
+
+		if bound > p.sent {
+			p.sent = bound
+			if err := s.post(p, Message{Kind: NullMessage, Time: bound}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// post puts the message into the mailbox of the port, while it is full the object takes
+// its own messages so objects feeding each other can't block forever
+func (s *Simulator) post(p *Port, m Message) error {
+	atomic.AddInt64(&p.Mailbox.sent[m.Kind], 1)
+	select {
+	case p.Mailbox.messages <- m:
+		p.To.wakeUp()
+		return nil
+	default:
+	}
+
+	atomic.AddInt64(&p.Mailbox.waits, 1)
+	s.setWaiting(p.To.Name + " to take messages")
+	for {
+		select {
+		case p.Mailbox.messages <- m:
+			s.setWaiting("")
+			p.To.wakeUp()
+			return nil
+		case <-s.wake:
+			s.takeMessages()
+		case <-s.status.done:
+			return s.stopped()
+		}
+	}
+}
+
+func (s *Simulator) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// waitPorts blocks until a port brings something or the group moves on
+func (s *Simulator) waitPorts(m *portMember, next float64) error {
+	s.group.Lock()
+	m.next = next
+	m.blocked = true
+	s.group.recover()
+	s.group.Unlock()
+
+	safe := s.safeTime(m)
+	var from []string
+	for _, p := range s.InPorts {
+		if p.clock <= safe {
+			from = append(from, p.From.Name)
+		}
+	}
+	s.setWaiting("markers from " + strings.Join(from, ", "))
+
+	var err error
+	select {
+	case <-s.wake:
+		s.setWaiting("")
+	case <-s.status.done:
+		err = s.stopped()
+	}
+
+	s.group.Lock()
+	m.blocked = false
+	s.group.Unlock()
+	return err
+}
+
+// recover breaks a deadlock: when every running object of the group is blocked and no
+// message is on its way, nothing can come before the earliest event of all objects
+func (g *portGroup) recover() {
+	earliest := math.Inf(1)
+	stuck := false
+	for _, m := range g.members {
+		if m.done {
+			continue
+		}
+
+		if !m.blocked {
+			return
+		}
+
+		for _, p := range m.obj.InPorts {
+			if p.Mailbox.Len() > 0 {
+				return
+			}
+		}
+
+		earliest = math.Min(earliest, m.next)
+		stuck = true
+	}
+
+	if !stuck {
+		return
+	}
+
+	raised := false
+	for _, m := range g.members {
+		floor := earliest
+		if floor > m.end {
+			floor = math.Inf(1)
+		}
+
+		if !m.done && m.floor < floor {
+			m.floor = floor
+			raised = true
+			m.obj.wakeUp()
+		}
+	}
+
+	if raised {
+		g.recoveries++
+		log.Printf("deadlock of linked objects, all move on to time %f", earliest)
+	}
+}
+
+// finishRun moves the object to the end of simulation and promises the fed objects no more firings
+func (s *Simulator) finishRun(m *portMember, end float64) error {
+	if end > s.TimeLocal {
+		s.MoveTimeLocal(end)
+	}
+
+	for _, p := range s.OutPorts {
+		if !math.IsInf(p.sent, 1) {
+			p.sent = math.Inf(1)
+			if err := s.post(p, Message{Kind: EndOfSimulation, Time: end}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m != nil {
+		s.group.Lock()
+		m.done = true
+		s.group.recover()
+		s.group.Unlock()
+	}
+
+	return nil
+}
+
+// portPlaces are the places markers of other objects come to or go from through ports
+func (s *Simulator) portPlaces() map[*Place]bool {
+	places := make(map[*Place]bool)
+	for _, p := range s.InPorts {
+		places[s.Places[p.Place]] = true
+	}
+
+	for _, p := range s.OutPorts {
+		if p.source >= 0 {
+			places[s.Places[p.source]] = true
+		}
+	}
+
+	return places
+}
@@ -2,42 +2,238 @@ package petri
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 )
 
-func generate() (r float64) {
-	for r == 0 {
-		r = rand.Float64()
+// RandomStream is a source of random numbers owned by a model, an object or a transition,
+// *rand.Rand satisfies it
+type RandomStream interface {
+	Float64() float64
+	NormFloat64() float64
+	Intn(int) int
+}
+
+// globalStream draws from the shared math/rand source, it is used when no stream was injected
+type globalStream struct{}
+
+func (globalStream) Float64() float64 {
+	return rand.Float64()
+}
+
+func (globalStream) NormFloat64() float64 {
+	return rand.NormFloat64()
+}
+
+func (globalStream) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+func streamOrGlobal(r RandomStream) RandomStream {
+	if r == nil {
+		return globalStream{}
 	}
 
 	return r
 }
 
+// Streams derives independent substreams from one seed, the same seed and keys
+// always give the same stream, so scenarios share common random numbers
+type Streams struct {
+	Seed int64
+}
+
+func (s *Streams) Stream(keys ...interface{}) RandomStream {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d", s.Seed)
+	for _, k := range keys {
+		fmt.Fprintf(h, "/%v", k)
+	}
+
+	src := &splitMixSource{}
+	src.Seed(int64(splitMix64(h.Sum64())))
+	return &stream{Rand: rand.New(src), source: src}
+}
+
+// stream is a substream whose state can be saved and restored, the optimistic
+// engine rolls it back together with the object
+type stream struct {
+	*rand.Rand
+	source *splitMixSource
+}
+
+// splitMixSource is a rand.Source64 with one word of state
+type splitMixSource struct {
+	state uint64
+}
+
+func (s *splitMixSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *splitMixSource) Uint64() uint64 {
+	v := splitMix64(s.state)
+	s.state += 0x9e3779b97f4a7c15
+	return v
+}
+
+func (s *splitMixSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// streamState reads the state of a stream made by Streams, ok is false for other streams
+func streamState(r RandomStream) (v uint64, ok bool) {
+	if s, ok := r.(*stream); ok {
+		return s.source.state, true
+	}
+
+	return 0, false
+}
+
+func setStreamState(r RandomStream, v uint64) {
+	if s, ok := r.(*stream); ok {
+		s.source.state = v
+	}
+}
+
+// splitMix64 spreads close hashes over the whole range of seeds
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func generate(r RandomStream) (v float64) {
+	for v == 0 {
+		v = r.Float64()
+	}
+
+	return v
+}
+
 func Exp(timeMean float64) float64 {
-	return -timeMean * math.Log(generate())
+	return ExpFrom(globalStream{}, timeMean)
 }
 
 func Uniform(timeMin float64, timeMax float64) float64 {
-	return timeMin + generate()*(timeMax-timeMin)
+	return UniformFrom(globalStream{}, timeMin, timeMax)
 }
 
 func Normal(timeMean float64, timeDeviation float64) float64 {
-	return timeMean + timeDeviation*rand.NormFloat64()
+	return NormalFrom(globalStream{}, timeMean, timeDeviation)
 }
 
+// ExpFrom is Exp drawing from the stream
+func ExpFrom(r RandomStream, timeMean float64) float64 {
+	return -timeMean * math.Log(generate(r))
+}
+
+// UniformFrom is Uniform drawing from the stream
+func UniformFrom(r RandomStream, timeMin float64, timeMax float64) float64 {
+	return timeMin + generate(r)*(timeMax-timeMin)
+}
+
+// NormalFrom is Normal drawing from the stream
+func NormalFrom(r RandomStream, timeMean float64, timeDeviation float64) float64 {
+	return timeMean + timeDeviation*r.NormFloat64()
+}
+
+// TruncatedNormal resamples until the value is not less than min, after many misses min is returned
+func TruncatedNormal(r RandomStream, timeMean float64, timeDeviation float64, min float64) float64 {
+	for i := 0; i < 1000; i++ {
+		if v := NormalFrom(r, timeMean, timeDeviation); v >= min {
+			return v
+		}
+	}
+
+	return min
+}
+
+// Erlang is a sum of k exponential phases with the given total mean
+func Erlang(r RandomStream, k int, timeMean float64) float64 {
+	p := 1.0
+	for i := 0; i < k; i++ {
+		p *= generate(r)
+	}
+
+	return -timeMean / float64(k) * math.Log(p)
+}
+
+// Gamma uses the method of Marsaglia and Tsang
+func Gamma(r RandomStream, shape float64, scale float64) float64 {
+	if shape < 1 {
+		return Gamma(r, shape+1, scale) * math.Pow(generate(r), 1/shape)
+	}
+
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+
+		v = v * v * v
+		u := generate(r)
+		if math.Log(u) < x*x/2+d-d*v+d*math.Log(v) {
+			return d * v * scale
+		}
+	}
+}
+
+func LogNormal(r RandomStream, mu float64, sigma float64) float64 {
+	return math.Exp(mu + sigma*r.NormFloat64())
+}
+
+func Weibull(r RandomStream, shape float64, scale float64) float64 {
+	return scale * math.Pow(-math.Log(generate(r)), 1/shape)
+}
+
+func Triangular(r RandomStream, min float64, mode float64, max float64) float64 {
+	u := r.Float64()
+	f := (mode - min) / (max - min)
+	if u < f {
+		return min + math.Sqrt(u*(max-min)*(mode-min))
+	}
+
+	return max - math.Sqrt((1-u)*(max-min)*(max-mode))
+}
+
+// HyperExp takes the first exponential phase with probability p and the second one otherwise
+func HyperExp(r RandomStream, p float64, timeMean1 float64, timeMean2 float64) float64 {
+	if r.Float64() < p {
+		return ExpFrom(r, timeMean1)
+	}
+
+	return ExpFrom(r, timeMean2)
+}
+
+// Empiric interpolates the inverse of a piecewise linear distribution function
+// given by points x and cumulative probabilities y
 func Empiric(x []float64, y []float64) (v float64, err error) {
+	return EmpiricFrom(globalStream{}, x, y)
+}
+
+// EmpiricFrom is Empiric drawing from the stream
+func EmpiricFrom(r RandomStream, x []float64, y []float64) (v float64, err error) {
 	n := len(x) - 1
-	if y[n] != 1.0 {
+	if n < 1 || len(y) != len(x) || y[n] != 1.0 {
 		return v, fmt.Errorf("illegal array of points for empiric distribution")
 	}
 
-	r := rand.Float64()
-	for i := 1; i < len(x)-1; i++ {
-		if r > y[i-1] && r <= y[i] {
-			return x[i-1] + (r-y[i-1])*(x[i]-x[i-1])/(y[i]-y[i-1]), nil
+	p := r.Float64()
+	for i := 1; i < n; i++ {
+		if p > y[i-1] && p <= y[i] {
+			return x[i-1] + (p-y[i-1])*(x[i]-x[i-1])/(y[i]-y[i-1]), nil
 		}
 	}
 
-	return x[n-2] + (r-y[n-2])*(x[n-1]-x[n-2])/(y[n-1]-y[n-2]), nil
+	if y[n] == y[n-1] {
+		return x[n], nil
+	}
+
+	return x[n-1] + (p-y[n-1])*(x[n]-x[n-1])/(y[n]-y[n-1]), nil
 }
@@ -0,0 +1,330 @@
+package petri
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxStates bounds the state space explored when no limit is given
+const DefaultMaxStates = 100000
+
+// Marking holds the number of markers per place, in the order of Net.Places
+type Marking []float64
+
+func (m Marking) key() string {
+	var b strings.Builder
+	for i, v := range m {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(formatMarking(v))
+	}
+
+	return b.String()
+}
+
+func formatMarking(v float64) string {
+	if math.IsInf(v, 1) {
+		return "ω"
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Format lists marked places with names, e.g. "P0=1 P2=3"
+func (m Marking) Format(places []string) string {
+	var parts []string
+	for i, v := range m {
+		if v != 0 {
+			parts = append(parts, places[i]+"="+formatMarking(v))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "empty"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// netArc is an arc of the untimed net: a place index and a weight
+type netArc struct {
+	place  int
+	weight float64
+}
+
+// netStructure is the net without time: arcs grouped per transition in the order of Net.Transitions
+type netStructure struct {
+	places      []string
+	transitions []string
+
+	pre       [][]netArc
+	info      [][]netArc
+	inhibitor [][]netArc
+	reset     [][]int
+	post      [][]netArc
+
+	// transitions of zero probability are never chosen by the simulator
+	disabled []bool
+}
+
+func newNetStructure(n *Net) *netStructure {
+	s := &netStructure{}
+	index := make(map[int]int)
+	for i, t := range n.Transitions {
+		index[t.Number] = i
+		s.transitions = append(s.transitions, t.Name)
+		s.disabled = append(s.disabled, t.Probability == 0)
+	}
+
+	for _, p := range n.Places {
+		s.places = append(s.places, p.Name)
+	}
+
+	s.pre = make([][]netArc, len(n.Transitions))
+	s.info = make([][]netArc, len(n.Transitions))
+	s.inhibitor = make([][]netArc, len(n.Transitions))
+	s.reset = make([][]int, len(n.Transitions))
+	s.post = make([][]netArc, len(n.Transitions))
+
+	for _, l := range n.LinksIn {
+		t, ok := index[l.CounterTransitions]
+		if !ok || l.CounterPlaces < 0 || l.CounterPlaces >= len(n.Places) {
+			continue
+		}
+
+		a := netArc{place: l.CounterPlaces, weight: float64(l.KVariant)}
+		switch {
+		case l.Inhibitor:
+			s.inhibitor[t] = append(s.inhibitor[t], a)
+		case l.Reset:
+			s.reset[t] = append(s.reset[t], l.CounterPlaces)
+		case l.Info:
+			s.info[t] = append(s.info[t], a)
+		default:
+			s.pre[t] = append(s.pre[t], a)
+		}
+	}
+
+	for _, l := range n.LinksOut {
+		t, ok := index[l.CounterTransitions]
+		if !ok || l.CounterPlaces < 0 || l.CounterPlaces >= len(n.Places) {
+			continue
+		}
+
+		s.post[t] = append(s.post[t], netArc{place: l.CounterPlaces, weight: float64(l.KVariant)})
+	}
+
+	return s
+}
+
+func (s *netStructure) initial(n *Net) Marking {
+	m := make(Marking, len(n.Places))
+	for i, p := range n.Places {
+		m[i] = p.Mark
+	}
+
+	return m
+}
+
+// enabled checks the arcs of the transition, guards are Go code and are left out
+func (s *netStructure) enabled(m Marking, t int) bool {
+	if s.disabled[t] {
+		return false
+	}
+
+	for _, a := range s.pre[t] {
+		if m[a.place] < a.weight {
+			return false
+		}
+	}
+
+	for _, a := range s.info[t] {
+		if m[a.place] < a.weight {
+			return false
+		}
+	}
+
+	for _, a := range s.inhibitor[t] {
+		if m[a.place] >= a.weight {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *netStructure) fire(m Marking, t int) Marking {
+	next := make(Marking, len(m))
+	copy(next, m)
+
+	for _, a := range s.pre[t] {
+		next[a.place] -= a.weight
+	}
+
+	for _, p := range s.reset[t] {
+		next[p] = 0
+	}
+
+	for _, a := range s.post[t] {
+		next[a.place] += a.weight
+	}
+
+	return next
+}
+
+type ReachabilityState struct {
+	ID      int     `json:"id"`
+	Marking Marking `json:"marking"`
+
+	// the state is first reached from Parent by firing Via, -1 for the initial state
+	Parent int `json:"-"`
+	Via    int `json:"-"`
+}
+
+type ReachabilityEdge struct {
+	From       int `json:"from"`
+	To         int `json:"to"`
+	Transition int `json:"transition"` // index in Transitions
+}
+
+// ReachabilityGraph holds the markings reachable from the initial one when time is ignored,
+// states are numbered in breadth-first order from 0, the initial marking
+type ReachabilityGraph struct {
+	Name        string               `json:"name"`
+	Places      []string             `json:"places"`
+	Transitions []string             `json:"transitions"`
+	States      []*ReachabilityState `json:"states"`
+	Edges       []ReachabilityEdge   `json:"edges"`
+	Complete    bool                 `json:"complete"` // false when the state limit stopped the search
+
+	out [][]int // edges leaving each state
+	cut []bool  // successors of the state were dropped by the state limit
+}
+
+// ReachabilityGraph explores markings breadth first until all are found or maxStates
+// states are known, maxStates <= 0 means DefaultMaxStates. An incomplete graph is
+// returned together with an error.
+func (n *Net) ReachabilityGraph(maxStates int) (*ReachabilityGraph, error) {
+	if maxStates <= 0 {
+		maxStates = DefaultMaxStates
+	}
+
+	s := newNetStructure(n)
+	g := &ReachabilityGraph{Name: n.Name, Places: s.places, Transitions: s.transitions, Complete: true}
+	index := make(map[string]int)
+
+	add := func(m Marking, parent int, via int) int {
+		state := &ReachabilityState{ID: len(g.States), Marking: m, Parent: parent, Via: via}
+		index[m.key()] = state.ID
+		g.States = append(g.States, state)
+		g.out = append(g.out, nil)
+		g.cut = append(g.cut, false)
+		return state.ID
+	}
+	add(s.initial(n), -1, -1)
+
+	for i := 0; i < len(g.States); i++ {
+		m := g.States[i].Marking
+		for t := range s.transitions {
+			if !s.enabled(m, t) {
+				continue
+			}
+
+			next := s.fire(m, t)
+			to, ok := index[next.key()]
+			if !ok {
+				if len(g.States) >= maxStates {
+					g.Complete = false
+					g.cut[i] = true
+					continue
+				}
+				to = add(next, i, t)
+			}
+
+			g.out[i] = append(g.out[i], len(g.Edges))
+			g.Edges = append(g.Edges, ReachabilityEdge{From: i, To: to, Transition: t})
+		}
+	}
+
+	if !g.Complete {
+		return g, fmt.Errorf("net %s: reachability graph exceeds %d states", n.Name, maxStates)
+	}
+
+	return g, nil
+}
+
+// Successors lists edges leaving the state
+func (g *ReachabilityGraph) Successors(state int) []ReachabilityEdge {
+	var edges []ReachabilityEdge
+	for _, e := range g.out[state] {
+		edges = append(edges, g.Edges[e])
+	}
+
+	return edges
+}
+
+// Dead lists states where no transition is enabled
+func (g *ReachabilityGraph) Dead() []int {
+	var dead []int
+	for i := range g.States {
+		if len(g.out[i]) == 0 && !g.cut[i] {
+			dead = append(dead, i)
+		}
+	}
+
+	return dead
+}
+
+// Path is the shortest firing sequence from the initial marking to the state
+func (g *ReachabilityGraph) Path(state int) []string {
+	var path []string
+	for s := g.States[state]; s.Parent >= 0; s = g.States[s.Parent] {
+		path = append([]string{g.Transitions[s.Via]}, path...)
+	}
+
+	return path
+}
+
+func WriteReachabilityDot(w io.Writer, g *ReachabilityGraph) error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "digraph %s {\n", quoteDot(g.Name))
+	b.WriteString("  node [shape=box, style=rounded, fontname=\"Helvetica\", fontsize=10];\n  edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	dead := make(map[int]bool)
+	for _, s := range g.Dead() {
+		dead[s] = true
+	}
+
+	for _, s := range g.States {
+		attrs := ""
+		if s.ID == 0 {
+			attrs = ", penwidth=2"
+		}
+		if dead[s.ID] {
+			attrs += ", color=red"
+		}
+		fmt.Fprintf(&b, "  s%d [label=%s%s];\n", s.ID, quoteDot(fmt.Sprintf("s%d\n%s", s.ID, s.Marking.Format(g.Places))), attrs)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  s%d -> s%d [label=%s];\n", e.From, e.To, quoteDot(g.Transitions[e.Transition]))
+	}
+
+	b.WriteString("}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func EncodeReachabilityJSON(w io.Writer, g *ReachabilityGraph) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
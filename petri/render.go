@@ -0,0 +1,589 @@
+package petri
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type renderNode struct {
+	ID      string
+	Name    string
+	Label   string
+	Place   bool
+	Shared  bool
+	Cluster int // -1 outside of clusters
+
+	layer int
+	order float64
+	x     float64
+	y     float64
+}
+
+type renderEdge struct {
+	From      string
+	To        string
+	Label     string
+	Dashed    bool
+	Inhibitor bool
+	Reset     bool
+	Link      bool // link between objects of a model
+}
+
+type renderGraph struct {
+	Name     string
+	Clusters []string
+	Nodes    []*renderNode
+	Edges    []*renderEdge
+
+	index map[string]*renderNode
+}
+
+func (g *renderGraph) add(node *renderNode) {
+	if g.index == nil {
+		g.index = make(map[string]*renderNode)
+	}
+
+	g.index[node.ID] = node
+	g.Nodes = append(g.Nodes, node)
+}
+
+func formatMark(m float64) string {
+	return strconv.FormatFloat(m, 'f', -1, 64)
+}
+
+// addNet puts places and transitions of the net into the graph, places already
+// known from another object are reused
+func (g *renderGraph) addNet(n *Net, cluster int, prefix string, seen map[*Place]string) []string {
+	placeIDs := make([]string, len(n.Places))
+	for i, p := range n.Places {
+		if id, ok := seen[p]; ok {
+			placeIDs[i] = id
+			g.index[id].Shared = true
+			g.index[id].Cluster = -1
+			continue
+		}
+
+		id := fmt.Sprintf("%sp%d", prefix, i)
+		seen[p] = id
+		placeIDs[i] = id
+		g.add(&renderNode{ID: id, Name: p.Name, Label: fmt.Sprintf("%s\n%s", p.Name, formatMark(p.Mark)), Place: true, Cluster: cluster})
+	}
+
+	transitionIDs := make(map[int]string)
+	for i, t := range n.Transitions {
+		id := fmt.Sprintf("%st%d", prefix, i)
+		transitionIDs[t.Number] = id
+		g.add(&renderNode{ID: id, Name: t.Name, Label: fmt.Sprintf("%s\nbuffer: %d", t.Name, t.Buffer), Cluster: cluster})
+	}
+
+	for _, l := range n.LinksIn {
+		if l.CounterPlaces < 0 || l.CounterPlaces >= len(placeIDs) {
+			continue
+		}
+
+		label := arcLabel(l)
+		if l.Reset {
+			label = ""
+		}
+
+		g.Edges = append(g.Edges, &renderEdge{
+			From:      placeIDs[l.CounterPlaces],
+			To:        transitionIDs[l.CounterTransitions],
+			Label:     label,
+			Dashed:    l.Info,
+			Inhibitor: l.Inhibitor,
+			Reset:     l.Reset,
+		})
+	}
+
+	for _, l := range n.LinksOut {
+		if l.CounterPlaces < 0 || l.CounterPlaces >= len(placeIDs) {
+			continue
+		}
+
+		g.Edges = append(g.Edges, &renderEdge{
+			From:  transitionIDs[l.CounterTransitions],
+			To:    placeIDs[l.CounterPlaces],
+			Label: arcLabel(l),
+		})
+	}
+
+	var ids []string
+	for _, t := range n.Transitions {
+		ids = append(ids, transitionIDs[t.Number])
+	}
+
+	return ids
+}
+
+func arcLabel(l *Linker) string {
+	if l.Class == "" {
+		return weightLabel(l.KVariant)
+	}
+
+	return strings.TrimSpace(weightLabel(l.KVariant) + " " + l.Class)
+}
+
+func weightLabel(k int) string {
+	if k == 1 {
+		return ""
+	}
+
+	return strconv.Itoa(k)
+}
+
+func netRenderGraph(n *Net) *renderGraph {
+	g := &renderGraph{Name: n.Name}
+	g.addNet(n, -1, "", make(map[*Place]string))
+	return g
+}
+
+func modelRenderGraph(m *Model) *renderGraph {
+	g := &renderGraph{Name: "model"}
+	seen := make(map[*Place]string)
+	transitionIDs := make(map[*Transition]string)
+
+	for i, s := range m.Objects {
+		g.Clusters = append(g.Clusters, s.Name)
+		net := s.GetNet()
+		ids := g.addNet(&net, i, fmt.Sprintf("o%d_", i), seen)
+		for j, t := range net.Transitions {
+			transitionIDs[t] = ids[j]
+		}
+	}
+
+	linked := make(map[[2]string]bool)
+	link := func(from *Simulator, t int, to *Simulator, q int) {
+		for _, in := range to.Transitions {
+			for _, i := range in.InPlaces {
+				if i != q {
+					continue
+				}
+
+				edge := [2]string{transitionIDs[from.Transitions[t]], transitionIDs[in]}
+				if !linked[edge] {
+					linked[edge] = true
+					g.Edges = append(g.Edges, &renderEdge{From: edge[0], To: edge[1], Label: to.Name, Link: true})
+				}
+			}
+		}
+	}
+
+	for _, s := range m.Objects {
+		for _, p := range s.OutPorts {
+			link(s, p.Transition, p.To, p.Place)
+		}
+
+		// shared places not linked yet go from the objects feeding them to the others
+		for k, p := range s.Places {
+			if !s.feeds(k) {
+				continue
+			}
+
+			for _, to := range m.Objects {
+				if q := to.placeIndex(p); to != s && q >= 0 && !to.feeds(q) {
+					for t, tr := range s.Transitions {
+						for _, out := range tr.OutPlaces {
+							if out == k {
+								link(s, t, to, q)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+func quoteDot(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + s + `"`
+}
+
+func (g *renderGraph) writeDotNode(w io.Writer, n *renderNode, indent string) {
+	if n.Place {
+		shape := "circle"
+		if n.Shared {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(w, "%s%s [shape=%s, label=%s];\n", indent, quoteDot(n.ID), shape, quoteDot(n.Label))
+	} else {
+		fmt.Fprintf(w, "%s%s [shape=box, style=filled, fillcolor=black, width=0.1, height=0.6, label=\"\", xlabel=%s];\n",
+			indent, quoteDot(n.ID), quoteDot(n.Label))
+	}
+}
+
+func (g *renderGraph) writeDot(w io.Writer) error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "digraph %s {\n", quoteDot(g.Name))
+	b.WriteString("  rankdir=LR;\n  node [fontname=\"Helvetica\", fontsize=10];\n  edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	for c, name := range g.Clusters {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n    label=%s;\n", c, quoteDot(name))
+		for _, n := range g.Nodes {
+			if n.Cluster == c {
+				g.writeDotNode(&b, n, "    ")
+			}
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, n := range g.Nodes {
+		if n.Cluster < 0 || n.Cluster >= len(g.Clusters) {
+			g.writeDotNode(&b, n, "  ")
+		}
+	}
+
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Label != "" {
+			attrs = append(attrs, "label="+quoteDot(e.Label))
+		}
+		if e.Dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.Inhibitor {
+			attrs = append(attrs, "arrowhead=odot")
+		}
+		if e.Reset {
+			attrs = append(attrs, "arrowhead=normalnormal", "style=dotted")
+		}
+		if e.Link {
+			attrs = append(attrs, "style=bold", "color=blue", "constraint=false")
+		}
+
+		fmt.Fprintf(&b, "  %s -> %s", quoteDot(e.From), quoteDot(e.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+
+	b.WriteString("}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func WriteNetDot(w io.Writer, n *Net) error {
+	return netRenderGraph(n).writeDot(w)
+}
+
+func WriteModelDot(w io.Writer, m *Model) error {
+	return modelRenderGraph(m).writeDot(w)
+}
+
+// WriteNetSVG renders the net with Graphviz when the dot binary is available,
+// otherwise with the built-in layout
+func WriteNetSVG(w io.Writer, n *Net) error {
+	return netRenderGraph(n).writeSVG(w)
+}
+
+func WriteModelSVG(w io.Writer, m *Model) error {
+	return modelRenderGraph(m).writeSVG(w)
+}
+
+func (g *renderGraph) writeSVG(w io.Writer) error {
+	if path, err := exec.LookPath("dot"); err == nil {
+		var dot, svg bytes.Buffer
+		g.writeDot(&dot)
+
+		cmd := exec.Command(path, "-Tsvg")
+		cmd.Stdin = &dot
+		cmd.Stdout = &svg
+		if err := cmd.Run(); err == nil {
+			_, err = w.Write(svg.Bytes())
+			return err
+		}
+	}
+
+	return g.writeLayoutSVG(w)
+}
+
+// layout assigns layers by longest path ignoring back edges and orders nodes
+// inside of a layer by barycenters of their predecessors
+func (g *renderGraph) layout() {
+	successors := make(map[string][]string)
+	for _, e := range g.Edges {
+		if !e.Link {
+			successors[e.From] = append(successors[e.From], e.To)
+		}
+	}
+
+	const (
+		white = iota
+		grey
+		black
+	)
+
+	state := make(map[string]int)
+	back := make(map[[2]string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = grey
+		for _, next := range successors[id] {
+			switch state[next] {
+			case white:
+				visit(next)
+			case grey:
+				back[[2]string{id, next}] = true
+			}
+		}
+		state[id] = black
+	}
+
+	for _, n := range g.Nodes {
+		if state[n.ID] == white {
+			visit(n.ID)
+		}
+	}
+
+	// nodes are relaxed as many times as there are nodes, enough for a DAG
+	for i := 0; i < len(g.Nodes); i++ {
+		changed := false
+		for _, n := range g.Nodes {
+			for _, next := range successors[n.ID] {
+				if back[[2]string{n.ID, next}] {
+					continue
+				}
+
+				if m := g.index[next]; m.layer < n.layer+1 {
+					m.layer = n.layer + 1
+					changed = true
+				}
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	// clusters are put one after another leaving a layer for shared places in between
+	if len(g.Clusters) > 0 {
+		offset := 0
+		for c := range g.Clusters {
+			minLayer, maxLayer := math.MaxInt32, -1
+			for _, n := range g.Nodes {
+				if n.Cluster == c {
+					if n.layer < minLayer {
+						minLayer = n.layer
+					}
+					if n.layer > maxLayer {
+						maxLayer = n.layer
+					}
+				}
+			}
+
+			if maxLayer < 0 {
+				continue
+			}
+
+			for _, n := range g.Nodes {
+				if n.Cluster == c {
+					n.layer += offset - minLayer
+				}
+			}
+			offset += maxLayer - minLayer + 2
+		}
+
+		for _, n := range g.Nodes {
+			if n.Cluster >= 0 {
+				continue
+			}
+
+			n.layer = 0
+			for _, e := range g.Edges {
+				if e.To == n.ID && !e.Link {
+					if from := g.index[e.From]; from.Cluster >= 0 && from.layer >= n.layer {
+						n.layer = from.layer + 1
+					}
+				}
+			}
+		}
+	}
+
+	layers := make(map[int][]*renderNode)
+	maxLayer := 0
+	for i, n := range g.Nodes {
+		n.order = float64(i)
+		layers[n.layer] = append(layers[n.layer], n)
+		if n.layer > maxLayer {
+			maxLayer = n.layer
+		}
+	}
+
+	predecessors := make(map[string][]*renderNode)
+	for _, e := range g.Edges {
+		predecessors[e.To] = append(predecessors[e.To], g.index[e.From])
+	}
+
+	for l := 0; l <= maxLayer; l++ {
+		nodes := layers[l]
+		for i, n := range nodes {
+			n.order = float64(i)
+			if l == 0 {
+				continue
+			}
+
+			sum, count := 0.0, 0
+			for _, p := range predecessors[n.ID] {
+				if p.layer < l {
+					sum += p.y
+					count++
+				}
+			}
+
+			if count > 0 {
+				n.order = sum / float64(count)
+			}
+		}
+
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].order < nodes[j].order
+		})
+
+		for i, n := range nodes {
+			n.x = 60 + float64(l)*110
+			n.y = 60 + float64(i)*90
+		}
+	}
+}
+
+func escapeSVG(s string) string {
+	s = strings.Replace(s, "&", "&amp;", -1)
+	s = strings.Replace(s, "<", "&lt;", -1)
+	s = strings.Replace(s, ">", "&gt;", -1)
+	s = strings.Replace(s, `"`, "&quot;", -1)
+	return s
+}
+
+func (g *renderGraph) writeLayoutSVG(w io.Writer) error {
+	g.layout()
+
+	const (
+		radius = 18.0
+		barW   = 8.0
+		barH   = 36.0
+	)
+
+	width, height := 0.0, 0.0
+	for _, n := range g.Nodes {
+		width = math.Max(width, n.x+80)
+		height = math.Max(height, n.y+70)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" font-family=\"Helvetica\" font-size=\"10\">\n", width, height)
+	b.WriteString("<defs>" +
+		"<marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\">" +
+		"<path d=\"M 0 0 L 10 5 L 0 10 z\"/></marker>" +
+		"<marker id=\"inhibitor\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\">" +
+		"<circle cx=\"5\" cy=\"5\" r=\"4\" fill=\"white\" stroke=\"black\"/></marker>" +
+		"<marker id=\"reset\" viewBox=\"0 0 20 10\" refX=\"20\" refY=\"5\" markerWidth=\"12\" markerHeight=\"6\" orient=\"auto\">" +
+		"<path d=\"M 0 0 L 10 5 L 0 10 z M 10 0 L 20 5 L 10 10 z\"/></marker>" +
+		"</defs>\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", escapeSVG(g.Name))
+
+	for c, name := range g.Clusters {
+		minX, minY, maxX, maxY := math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64
+		for _, n := range g.Nodes {
+			if n.Cluster == c {
+				minX, minY = math.Min(minX, n.x), math.Min(minY, n.y)
+				maxX, maxY = math.Max(maxX, n.x), math.Max(maxY, n.y)
+			}
+		}
+
+		if minX > maxX {
+			continue
+		}
+
+		fmt.Fprintf(&b, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"none\" stroke=\"grey\"/>\n",
+			minX-40, minY-45, maxX-minX+80, maxY-minY+90)
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\" fill=\"grey\">%s</text>\n", minX-36, minY-33, escapeSVG(name))
+	}
+
+	for _, e := range g.Edges {
+		from, to := g.index[e.From], g.index[e.To]
+		if from == nil || to == nil {
+			continue
+		}
+
+		// cut the line at the border of the target
+		dx, dy := to.x-from.x, to.y-from.y
+		dist := math.Hypot(dx, dy)
+		if dist == 0 {
+			continue
+		}
+
+		cut := radius
+		if !to.Place {
+			cut = barW
+		}
+		x1, y1 := from.x, from.y
+		x2, y2 := to.x-dx/dist*cut, to.y-dy/dist*cut
+
+		style := "stroke=\"black\""
+		if e.Dashed {
+			style += " stroke-dasharray=\"4,3\""
+		}
+		if e.Reset {
+			style += " stroke-dasharray=\"1,2\""
+		}
+		if e.Link {
+			style = "stroke=\"blue\" stroke-width=\"2\""
+		}
+
+		marker := "arrow"
+		if e.Inhibitor {
+			marker = "inhibitor"
+		} else if e.Reset {
+			marker = "reset"
+		}
+
+		if to.layer <= from.layer {
+			// back edges are bent to not overlap with forward ones
+			cx, cy := (x1+x2)/2-dy/4, (y1+y2)/2+dx/4-40
+			fmt.Fprintf(&b, "<path d=\"M %.1f %.1f Q %.1f %.1f %.1f %.1f\" fill=\"none\" %s marker-end=\"url(#%s)\"/>\n", x1, y1, cx, cy, x2, y2, style, marker)
+		} else {
+			fmt.Fprintf(&b, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" %s marker-end=\"url(#%s)\"/>\n", x1, y1, x2, y2, style, marker)
+		}
+
+		if e.Label != "" {
+			fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\">%s</text>\n", (x1+x2)/2, (y1+y2)/2-4, escapeSVG(e.Label))
+		}
+	}
+
+	for _, n := range g.Nodes {
+		lines := strings.Split(n.Label, "\n")
+		if n.Place {
+			fmt.Fprintf(&b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.1f\" fill=\"white\" stroke=\"black\"/>\n", n.x, n.y, radius)
+			if n.Shared {
+				fmt.Fprintf(&b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.1f\" fill=\"none\" stroke=\"black\"/>\n", n.x, n.y, radius-3)
+			}
+			fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\">%s</text>\n", n.x, n.y+4, escapeSVG(lines[len(lines)-1]))
+			fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\">%s</text>\n", n.x, n.y+radius+12, escapeSVG(lines[0]))
+		} else {
+			fmt.Fprintf(&b, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"black\"/>\n", n.x-barW/2, n.y-barH/2, barW, barH)
+			for i, line := range lines {
+				fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\">%s</text>\n", n.x, n.y-barH/2-4-float64(len(lines)-1-i)*11, escapeSVG(line))
+			}
+		}
+	}
+
+	b.WriteString("</svg>\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,254 @@
+package petri
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Replications runs independent replications of a model at once and sums them up. Every
+// replication gets a fresh model from the factory and its own seed, a bounded pool of
+// workers runs them. Means of places and transitions are collected per replication and
+// given as mean, standard deviation and Student-t confidence interval over replications.
+type Replications struct {
+	N            int
+	Workers      int     // replications running at once, the number of CPUs
+	Seed         int64   // replication i runs with seed Seed+i
+	Level        float64 // confidence level of the intervals, 0.95
+	TimeModeling float64
+
+	// Simulate runs a replication, GoRunContext until TimeModeling by default
+	Simulate func(context.Context, *Model) error
+
+	Places      []*PlaceEstimate
+	Transitions []*TransitionEstimate
+
+	factory func(int) *Model
+}
+
+type BuildReplications interface {
+	Build(func(int) *Model, int, float64) *Replications
+	Run(context.Context) error
+	Place(string, string) *PlaceEstimate
+	Transition(string, string) *TransitionEstimate
+}
+
+// Estimate is a statistic over replications
+type Estimate struct {
+	N         int
+	Mean      float64
+	Std       float64
+	HalfWidth float64 // of the confidence interval of the mean
+}
+
+func (e Estimate) Low() float64 {
+	return e.Mean - e.HalfWidth
+}
+
+func (e Estimate) High() float64 {
+	return e.Mean + e.HalfWidth
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%f ± %f (std %f, n %d)", e.Mean, e.HalfWidth, e.Std, e.N)
+}
+
+type PlaceEstimate struct {
+	Object      string
+	Place       string
+	Mean        Estimate
+	ObservedMax Estimate
+	ObservedMin Estimate
+}
+
+type TransitionEstimate struct {
+	Object     string
+	Transition string
+	Mean       Estimate
+}
+
+// replicationSample holds the statistics of one replication in the order of its objects,
+// names aren't unique: objects sharing places may have two places of one name
+type replicationSample struct {
+	places      [][3]float64 // mean, observed max and min
+	placeKeys   []sampleKey
+	transitions []float64
+	transKeys   []sampleKey
+}
+
+type sampleKey struct {
+	object, index int
+	names         [2]string
+}
+
+func (r *Replications) Build(factory func(int) *Model, n int, timeModeling float64) *Replications {
+	r.factory = factory
+	r.N = n
+	r.TimeModeling = timeModeling
+	if r.Workers <= 0 {
+		r.Workers = runtime.NumCPU()
+	}
+	if r.Level <= 0 {
+		r.Level = 0.95
+	}
+
+	return r
+}
+
+// Run runs all replications and fills Places and Transitions, the first failed replication
+// stops the others
+func (r *Replications) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	simulate := r.Simulate
+	if simulate == nil {
+		simulate = func(ctx context.Context, m *Model) error {
+			return m.GoRunContext(ctx, r.TimeModeling)
+		}
+	}
+
+	samples := make([]*replicationSample, r.N)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var failed error
+
+	for w := 0; w < r.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				m := r.factory(i)
+				m.SetSeed(r.Seed + int64(i))
+				if err := simulate(ctx, m); err != nil {
+					once.Do(func() {
+						failed = fmt.Errorf("replication %d: %v", i, err)
+						cancel()
+					})
+					continue
+				}
+
+				samples[i] = sampleOf(m)
+			}
+		}()
+	}
+
+	for i := 0; i < r.N && ctx.Err() == nil; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if failed != nil {
+		return failed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.aggregate(samples)
+	return nil
+}
+
+func sampleOf(m *Model) *replicationSample {
+	s := &replicationSample{}
+	for k, obj := range m.Objects {
+		for i, p := range obj.Places {
+			s.places = append(s.places, [3]float64{p.Mean, p.ObservedMax, p.ObservedMin})
+			s.placeKeys = append(s.placeKeys, sampleKey{k, i, [2]string{obj.Name, p.Name}})
+		}
+
+		for i, t := range obj.Transitions {
+			s.transitions = append(s.transitions, t.Mean)
+			s.transKeys = append(s.transKeys, sampleKey{k, i, [2]string{obj.Name, t.Name}})
+		}
+	}
+
+	return s
+}
+
+// aggregate sums the samples up in the order of replications, so results don't depend on the workers
+func (r *Replications) aggregate(samples []*replicationSample) {
+	type placeTallies struct {
+		est            *PlaceEstimate
+		mean, max, min Tally
+	}
+	type transitionTallies struct {
+		est  *TransitionEstimate
+		mean Tally
+	}
+
+	var places []*placeTallies
+	var transitions []*transitionTallies
+	placeIndex := make(map[sampleKey]*placeTallies)
+	transitionIndex := make(map[sampleKey]*transitionTallies)
+
+	for _, s := range samples {
+		for i, key := range s.placeKeys {
+			pt, ok := placeIndex[key]
+			if !ok {
+				pt = &placeTallies{est: &PlaceEstimate{Object: key.names[0], Place: key.names[1]}}
+				placeIndex[key] = pt
+				places = append(places, pt)
+			}
+			pt.mean.Add(s.places[i][0])
+			pt.max.Add(s.places[i][1])
+			pt.min.Add(s.places[i][2])
+		}
+
+		for i, key := range s.transKeys {
+			tt, ok := transitionIndex[key]
+			if !ok {
+				tt = &transitionTallies{est: &TransitionEstimate{Object: key.names[0], Transition: key.names[1]}}
+				transitionIndex[key] = tt
+				transitions = append(transitions, tt)
+			}
+			tt.mean.Add(s.transitions[i])
+		}
+	}
+
+	r.Places = nil
+	for _, pt := range places {
+		pt.est.Mean = r.estimate(&pt.mean)
+		pt.est.ObservedMax = r.estimate(&pt.max)
+		pt.est.ObservedMin = r.estimate(&pt.min)
+		r.Places = append(r.Places, pt.est)
+	}
+
+	r.Transitions = nil
+	for _, tt := range transitions {
+		tt.est.Mean = r.estimate(&tt.mean)
+		r.Transitions = append(r.Transitions, tt.est)
+	}
+}
+
+func (r *Replications) estimate(t *Tally) Estimate {
+	return Estimate{N: t.Count, Mean: t.Mean(), Std: t.Std(), HalfWidth: t.HalfWidth(r.Level)}
+}
+
+// Place finds the estimates of a place of an object by names, the first one if names repeat
+func (r *Replications) Place(object, place string) *PlaceEstimate {
+	for _, p := range r.Places {
+		if p.Object == object && p.Place == place {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// Transition finds the estimates of a transition of an object by names, nil if there is none
+func (r *Replications) Transition(object, transition string) *TransitionEstimate {
+	for _, t := range r.Transitions {
+		if t.Object == object && t.Transition == transition {
+			return t
+		}
+	}
+
+	return nil
+}
@@ -1,12 +1,11 @@
 package petri
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
 	"os"
-	"reflect"
 	"sort"
 )
 
@@ -30,32 +29,40 @@ type Simulator struct {
 	LinksOut    []*Linker
 
 	EventMin *Transition
+	Calendar *EventCalendar // future events of the transitions
+	enabling *enablingSet
 	TNet     Net
 
 	StatisticsPlaces []*Place
+	WarmUp           float64                  // statistics start over at this time, see SetWarmUp
+	observers        []func(from, to float64) // see BatchMeans
 
-	Channel chan int
-
-	PrevObj *Simulator
-	NextObj *Simulator
-
-	TimeExternalInput []float64
-	OutT              []*Transition
-	InT               []*Transition
+	InPorts  []*Port // markers come from other objects, see Connect
+	OutPorts []*Port
+	group    *portGroup
+	wake     chan struct{} // a port brought something or the group moved on
 
 	BeginWait []string
 	EndWait   []string
 
-	Limit   int // 10
-	Counter int // 0
+	Limit int // messages the mailbox of a port holds before its sender waits, 10
+
+	Random RandomStream
+
+	invariants *invariantCheck
+
+	status *runStatus // see RunContext
 }
 
 type BuildSimulator interface {
 	Build(Net, *GlobalCounter, *GlobalTime, *GlobalLocker, chan int) *Simulator
 
 	GetEventMin() *Transition
-	GetTimeExternalInput() []float64 // atomic
 	SetPriority(int) BuildSimulator
+	SetStreams(*Streams) BuildSimulator
+	SetInvariantCheck(bool) BuildSimulator
+	SetWarmUp(float64) BuildSimulator
+	ResetStatistics()
 	ProcessEventMin()
 	FindActiveTransition() []*Transition
 	SortTransitionsByPriority([]*Transition) // inplace
@@ -66,43 +73,47 @@ type BuildSimulator interface {
 	CheckIfOutTransitions([]*Transition, *Transition) bool
 	Input()
 	Output()
-	ReinstateActOut(*Place, *Transition)
 	StepEvent()
 	IsStop() bool
 	DoStatistics()
 	DoStatisticsWithInterval(float64)
 	WriteStatistics()
 	Goo()
-	AddTimeExternalInput(float64)
 	IsStopSerial() bool
-	GoUntilConference(float64)
-	GoUntil(float64)
+	Connect(string, *Simulator, string) (*Port, error)
 	MoveTimeLocal(float64)
 	DoT()
 	Run()
+	RunContext(context.Context) error
+	Waiting() string
 	PrintState()
 	PrintBuffer()
 }
 
+// Build makes an object of the net. The cond and channel parameters are deprecated and
+// ignored, objects pass messages through ports instead, see Connect; pass nil for both.
+// They are kept only so old callers compile and go away together with GlobalLocker.
 func (s *Simulator) Build(n Net, c *GlobalCounter, t *GlobalTime, cond *GlobalLocker, channel chan int) *Simulator {
 	s.TNet = n
 	s.Name = n.Name
 	s.Gcounter = c
-	s.Channel = channel
+	s.status = &runStatus{}
 	s.InitNumObj()
 	s.IncrCounter()
 	s.Gtime = t
 	s.TimeLocal = s.Gtime.CurrentTime
 	s.TimeMin = math.MaxFloat64
 	s.Limit = 10
-	s.Counter = 0
-	copy(s.Places, n.Places)
-	copy(s.Transitions, n.Transitions)
-	copy(s.LinksIn, n.LinksIn)
-	copy(s.LinksOut, n.LinksOut)
+	s.wake = make(chan struct{}, 1)
+	s.Places = n.Places
+	s.Transitions = n.Transitions
+	s.LinksIn = n.LinksIn
+	s.LinksOut = n.LinksOut
+	s.Calendar = (&EventCalendar{}).Build(s.Transitions)
+	s.enabling = (&enablingSet{}).build(s.Transitions, s.Places)
 	s.EventMin = s.GetEventMin()
 	s.Priority = 0
-	copy(s.StatisticsPlaces, s.Places)
+	s.StatisticsPlaces = s.Places
 
 	// WARNING READ SOME FILE
 
@@ -126,12 +137,62 @@ func (s *Simulator) SetPriority(p int) BuildSimulator {
 	return s
 }
 
-func (s *Simulator) GetNet() Net {
-	return s.TNet
+// SetStreams gives the object and each of its transitions own substreams of random numbers
+func (s *Simulator) SetStreams(st *Streams) BuildSimulator {
+	s.Random = st.Stream("object", s.NumObject)
+	for _, t := range s.Transitions {
+		t.SetRandom(st.Stream("object", s.NumObject, "transition", t.Name))
+	}
+
+	return s
+}
+
+// SetWarmUp drops the transient: statistics of places and transitions start over at the time
+func (s *Simulator) SetWarmUp(t float64) BuildSimulator {
+	s.WarmUp = t
+	return s
+}
+
+func (s *Simulator) ResetStatistics() {
+	for _, p := range s.Places {
+		p.ResetStatistics()
+	}
+
+	for _, t := range s.Transitions {
+		t.ResetStatistics()
+	}
+}
+
+// observe tells the observers that marks and buffers held from one time to another
+func (s *Simulator) observe(from, to float64) {
+	for _, f := range s.observers {
+		f(from, to)
+	}
+}
+
+// warmUp starts statistics over when the step from one time to another passes the warm-up time
+func (s *Simulator) warmUp(from, to float64) {
+	if s.WarmUp > 0 && from <= s.WarmUp && s.WarmUp < to {
+		s.ResetStatistics()
+	}
+}
+
+// accumulate adds the step from one time to another to the means, after the warm-up
+// they are taken over the time since the warm-up
+func (s *Simulator) accumulate(from, to float64) {
+	start := 0.0
+	if s.WarmUp > 0 && to > s.WarmUp {
+		start = s.WarmUp
+		from = math.Max(from, start)
+	}
+
+	if to > from {
+		s.DoStatisticsWithInterval((to - from) / (to - start))
+	}
 }
 
-func (s *Simulator) GetTimeExternalInput() []float64 {
-	return s.TimeExternalInput
+func (s *Simulator) GetNet() Net {
+	return s.TNet
 }
 
 func (s *Simulator) GetEventMin() *Transition {
@@ -140,28 +201,23 @@ func (s *Simulator) GetEventMin() *Transition {
 }
 
 func (s *Simulator) ProcessEventMin() {
-	var event *Transition
-	min := math.MaxFloat64
+	s.TimeMin = s.Calendar.NextTime()
+	s.EventMin = s.Calendar.Next()
+}
 
-	for i := 0; i < len(s.Transitions); i++ {
-		if s.Transitions[i].MinTime < min {
-			event = s.Transitions[i]
-			min = s.Transitions[i].MinTime
-		}
+// nextDue is the transition with markers to put out at the time, nil if there is none
+func (s *Simulator) nextDue(time float64) *Transition {
+	t := s.Calendar.Next()
+	if t == nil || t.MinTime != time || t.Buffer == 0 {
+		return nil
 	}
 
-	s.TimeMin = min
-	s.EventMin = event
+	return t
 }
 
+// FindActiveTransition checks only transitions reading places that changed since the last call
 func (s *Simulator) FindActiveTransition() []*Transition {
-	var activeTransitions []*Transition
-
-	for i := 0; i < len(s.Transitions); i++ {
-		if s.Transitions[i].Condition(s.Places) && s.Transitions[i].Probability != 0 {
-			activeTransitions = append(activeTransitions, s.Transitions[i])
-		}
-	}
+	activeTransitions := s.enabling.enabled()
 
 	if len(activeTransitions) > 1 {
 		log.Printf("Before sorting: %v\n", activeTransitions)
@@ -229,41 +285,19 @@ func (s *Simulator) Step() {
 
 		// exit markers
 		s.EventMin.ActOut(s.Places)
-
-		if s.EventMin.Buffer > 0 {
-			u := true
-			for u {
-				s.EventMin.MinEvent()
-				if s.EventMin.MinTime == s.Gtime.CurrentTime {
-					s.EventMin.ActOut(s.Places)
-				} else {
-					u = false
-				}
-			}
+		for s.EventMin.Buffer > 0 && s.EventMin.MinTime == s.Gtime.CurrentTime {
+			s.EventMin.ActOut(s.Places)
 		}
 
 		// WARNING: Output from all transitions
 		// time of out markers == current time
-		for i := 0; i < len(s.Transitions); i++ {
-			if s.Transitions[i].Buffer > 0 && s.Transitions[i].MinTime == s.Gtime.CurrentTime {
-
-				// exit markers from transition that responds to the closest time range
-				s.Transitions[i].ActOut(s.Places)
-
-				if s.Transitions[i].Buffer > 0 {
-					u := true
-					for u {
-						s.Transitions[i].MinEvent()
-						if s.Transitions[i].MinTime == s.Gtime.CurrentTime {
-							s.Transitions[i].ActOut(s.Places)
-						} else {
-							u = false
-						}
-					}
-				}
-			}
+		for t := s.nextDue(s.Gtime.CurrentTime); t != nil; t = s.nextDue(s.Gtime.CurrentTime) {
+			// exit markers from transition that responds to the closest time range
+			t.ActOut(s.Places)
 		}
 	}
+
+	s.checkInvariants()
 }
 
 func (s *Simulator) DoConflict(t []*Transition) *Transition {
@@ -276,7 +310,7 @@ func (s *Simulator) DoConflict(t []*Transition) *Transition {
 		}
 
 		if i > 1 {
-			r := rand.Float64()
+			r := streamOrGlobal(s.Random).Float64()
 
 			j := 0
 			var sum float64 = 0
@@ -337,50 +371,13 @@ func (s *Simulator) Input() {
 
 		s.ProcessEventMin()
 	}
+
+	s.checkInvariants()
 }
 
 func (s *Simulator) Output() {
-	var externalPlace *Place
-	if s.NextObj != nil {
-		externalPlace = s.Places[len(s.Places)-1]
-		externalPlace.External = true
-	}
-
-	for i := 0; i < len(s.Transitions); i++ {
-		if s.Transitions[i].MinTime == s.TimeLocal && s.Transitions[i].Buffer > 0 {
-			s.Transitions[i].ActOut(s.Places)
-
-			if s.NextObj != nil && s.CheckIfOutTransitions(s.OutT, s.Transitions[i]) {
-				s.NextObj.AddTimeExternalInput(s.TimeLocal)
-				s.NextObj.Channel <- 1
-
-				for len(s.NextObj.TimeExternalInput) > s.Limit {
-					log.Println("Wait for others")
-					<-s.NextObj.Channel
-					log.Println("Continue to processed further")
-				}
-			}
-
-			if s.Transitions[i].Buffer > 0 {
-				u := true
-				for u {
-					s.Transitions[i].MinEvent()
-					if s.Transitions[i].MinTime == s.TimeLocal {
-						s.Transitions[i].ActOut(s.Places)
-						if s.NextObj != nil && s.CheckIfOutTransitions(s.OutT, s.Transitions[i]) {
-							s.NextObj.AddTimeExternalInput(s.TimeLocal)
-							s.NextObj.Channel <- 1
-							for len(s.NextObj.TimeExternalInput) > s.Limit {
-								<-s.Channel
-							}
-						}
-					} else {
-						u = false
-					}
-				}
-			}
-
-		}
+	for t := s.nextDue(s.TimeLocal); t != nil; t = s.nextDue(s.TimeLocal) {
+		t.ActOut(s.Places)
 	}
 }
 
@@ -394,18 +391,6 @@ func (s *Simulator) CheckIfOutTransitions(t []*Transition, tofind *Transition) b
 	return false
 }
 
-func (s *Simulator) ReinstateActOut(p *Place, t *Transition) {
-	for i := 0; i < len(s.PrevObj.LinksOut); i++ {
-		if s.PrevObj.LinksOut[i].CounterTransitions == t.Number && s.PrevObj.LinksOut[i].CounterPlaces == p.Number {
-			p.IncrMark(float64(s.PrevObj.LinksOut[i].KVariant))
-			s.Counter++
-			break
-		} else {
-			log.Printf("%d == %d && %d == %d", s.PrevObj.LinksOut[i].CounterTransitions, t.Number, s.PrevObj.LinksOut[i].CounterPlaces, p.Number)
-		}
-	}
-}
-
 func (s *Simulator) StepEvent() {
 	if s.IsStop() {
 		s.TimeMin = math.MaxFloat64
@@ -413,6 +398,8 @@ func (s *Simulator) StepEvent() {
 		s.Output()
 		s.Input()
 	}
+
+	s.checkInvariants()
 }
 
 func (s *Simulator) IsStop() bool {
@@ -425,14 +412,8 @@ func (s *Simulator) IsStop() bool {
 		}
 	}
 
-	if s.PrevObj != nil {
-		if len(s.TimeExternalInput) > 0 {
-			return false
-		}
-	}
-
-	if s.NextObj != nil {
-		if len(s.NextObj.TimeExternalInput) > 10 {
+	for _, p := range s.InPorts {
+		if len(p.pending) > 0 {
 			return false
 		}
 	}
@@ -484,186 +465,71 @@ func (s *Simulator) Goo() {
 	}
 }
 
-func (s *Simulator) AddTimeExternalInput(t float64) {
-	s.NextObj.TimeExternalInput = append(s.NextObj.TimeExternalInput, s.TimeLocal)
-}
-
 func (s *Simulator) IsStopSerial() bool {
 	s.ProcessEventMin()
-	return reflect.DeepEqual(s.EventMin, nil)
-}
-
-func (s *Simulator) GoUntilConference(limitTime float64) {
-	limit := float64(s.Limit)
-	for s.TimeLocal < limit {
-		for s.IsStop() {
-			<-s.Channel
-		}
-
-		s.Input()
-		if s.TimeMin < limit {
-			s.DoStatisticsWithInterval((s.TimeMin - s.TimeLocal) / s.TimeMin) // maybe / s.Gtime.ModTime
-			s.TimeLocal = s.TimeMin
-			s.Output()
-		} else {
-			if s.PrevObj != nil && len(s.TimeExternalInput) > 0 {
-				if s.TimeExternalInput[len(s.TimeExternalInput)-1] < math.MaxFloat64 {
-					s.DoStatisticsWithInterval((limit - s.TimeLocal) / limit)
-					s.TimeLocal = limit
-				} else {
-					limit = s.Gtime.ModTime
-					s.DoStatisticsWithInterval((limit - s.TimeLocal) / limit)
-					s.TimeLocal = limit
-				}
-			} else {
-				s.DoStatisticsWithInterval((limit - s.TimeLocal) / limit)
-				s.TimeLocal = limit
-			}
-
-			if limit >= s.Gtime.ModTime {
-				if s.NextObj != nil {
-					s.NextObj.AddTimeExternalInput(math.MaxFloat64)
-					s.NextObj.Channel <- 1
-				}
-			}
-
-			if s.PrevObj != nil {
-				for len(s.TimeExternalInput) == 0 {
-					<-s.Channel
-				}
-			}
-
-			if s.TimeExternalInput[0] > s.Gtime.ModTime {
-				if s.NextObj != nil {
-					s.NextObj.AddTimeExternalInput(math.MaxFloat64)
-					s.NextObj.Channel <- 1
-				}
-			} else if s.TimeExternalInput[0] == s.TimeLocal {
-				s.ReinstateActOut(s.PrevObj.Places[len(s.PrevObj.Places)-1], s.PrevObj.OutT[0])
-				s.TimeExternalInput = s.TimeExternalInput[1:]
-
-				if len(s.TimeExternalInput) <= s.Limit {
-					s.PrevObj.Channel <- 1
-				}
-
-				for len(s.TimeExternalInput) == 0 {
-					<-s.Channel
-				}
-
-				if len(s.TimeExternalInput) > 0 {
-					if s.TimeExternalInput[0] > s.Gtime.ModTime {
-						if s.NextObj != nil {
-							s.NextObj.AddTimeExternalInput(math.MaxFloat64)
-							s.NextObj.Channel <- 1
-						}
-					} else {
-						if s.TimeExternalInput[len(s.TimeExternalInput)-1] < math.MaxFloat64 {
-							limit = s.TimeExternalInput[0]
-						} else {
-							limit = s.TimeExternalInput[len(s.TimeExternalInput)-1]
-						}
-					}
-				}
-			}
-		}
-	}
+	return s.EventMin == nil
 }
 
 func (s *Simulator) MoveTimeLocal(t float64) {
-	s.DoStatisticsWithInterval((t - s.TimeLocal) / t)
+	s.observe(s.TimeLocal, t)
+	s.warmUp(s.TimeLocal, t)
+	s.accumulate(s.TimeLocal, t)
 	s.TimeLocal = t
 }
 
-func (s *Simulator) GoUntil(limitTime float64) {
-	limit := limitTime
+// Run simulates the object in its own goroutine until Gtime.ModTime, markers come and go
+// through its ports. The object handles an event only when all input ports promise no
+// earlier firings, when it has to wait it promises the objects it feeds how far they may go.
+// Conservative runs the objects the same way.
+func (s *Simulator) Run() {
+	s.runContext(context.Background(), s.Gtime.ModTime)
+}
 
-	// propagate time within interval range
-	for s.TimeLocal < limit {
-		// checking preCondition start Input
-		for s.IsStop() {
-			log.Printf("%s is waiting for Input...\n", s.Name)
-			<-s.Channel
-		}
+// runUntil is the loop of Run, a cancelled object returns between steps or from a wait
+func (s *Simulator) runUntil(end float64) error {
+	m := s.startRun(end)
+	s.Input()
 
-		// timeMin changed
-		s.Input()
-		log.Printf("%s did Input, new value of timeMin: %f and limitTime: %f", s.Name, s.TimeMin, limit)
-		if s.TimeMin < limit {
-			s.MoveTimeLocal(s.TimeMin)
-			s.Output()
-		} else {
-			if limit >= s.Gtime.ModTime {
-				s.MoveTimeLocal(s.Gtime.ModTime)
-				if s.NextObj != nil {
-					s.NextObj.AddTimeExternalInput(math.MaxFloat64)
-					s.NextObj.Channel <- 1
-				}
-			} else {
-				if s.PrevObj != nil {
-					if len(s.TimeExternalInput) == 0 || s.TimeExternalInput[len(s.TimeExternalInput)-1] < math.MaxFloat64 {
-						for len(s.TimeExternalInput) == 0 {
-							<-s.Channel
-						}
-					}
-
-					if s.TimeExternalInput[0] > s.Gtime.ModTime {
-						s.MoveTimeLocal(s.Gtime.ModTime)
-						if s.NextObj != nil {
-							s.NextObj.AddTimeExternalInput(math.MaxFloat64)
-							s.NextObj.Channel <- 1
-						}
-					} else {
-						s.MoveTimeLocal(limit)
-						s.ReinstateActOut(s.PrevObj.Places[len(s.PrevObj.Places)-1], s.PrevObj.OutT[0])
-						s.TimeExternalInput = s.TimeExternalInput[1:]
-
-						if len(s.TimeExternalInput) <= s.Limit {
-							s.PrevObj.Channel <- 1
-						}
-					}
-				} else {
-					s.MoveTimeLocal(limit)
-				}
-			}
+	for {
+		if err := s.cancelled(); err != nil {
+			return err
 		}
-	}
-}
 
-func (s *Simulator) Run() {
-	for s.TimeLocal < s.Gtime.ModTime {
-		limitTime := s.Gtime.ModTime
-		if s.PrevObj != nil {
-			log.Printf("Lock: %s\n", s.Name)
-			for len(s.TimeExternalInput) == 0 {
-				log.Printf("Wait: %s\n", s.Name)
-				<-s.Channel
-			}
+		s.takeMessages()
+		next := s.nextPortEvent()
+		safe := s.safeTime(m)
 
-			log.Println("Len", len(s.TimeExternalInput))
-			limitTime = s.TimeExternalInput[0]
-			if limitTime > s.Gtime.ModTime {
-				limitTime = s.Gtime.ModTime
+		if next > end && safe > end {
+			if err := s.finishRun(m, end); err != nil {
+				return err
 			}
+			break
+		}
 
-			log.Printf("Unlock: %s\n", s.Name)
-		} else {
-			limitTime = s.Gtime.ModTime
+		if next <= safe && next <= end {
+			if err := s.handlePortEvent(next); err != nil {
+				return err
+			}
+			continue
 		}
 
-		if s.TimeLocal < limitTime {
-			log.Printf("%s will go until %f have local time %f\n", s.Name, limitTime, s.TimeLocal)
-			s.GoUntil(limitTime)
-		} else {
-			log.Printf("%s will not go until %f have local time %f >= time modeling!\n", s.Name, limitTime, s.TimeLocal)
-			return
+		if err := s.sendPromises(safe); err != nil {
+			return err
+		}
+		if err := s.waitPorts(m, next); err != nil {
+			return err
 		}
 	}
 
 	log.Printf("%s has finished simulation\n", s.Name)
 	s.PrintState()
+	return nil
 }
 
-func (s *Simulator) DoT() {}
+// DoT moves local time of the object to the global time of the serial run
+func (s *Simulator) DoT() {
+	s.TimeLocal = s.Gtime.CurrentTime
+}
 
 func (s *Simulator) PrintState() {
 	s.PrintMark()
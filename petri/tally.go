@@ -0,0 +1,146 @@
+package petri
+
+import (
+	"math"
+)
+
+// Tally accumulates observations such as waiting times of tokens
+type Tally struct {
+	Count int
+	Sum   float64
+	M2    float64 // sum of squared deviations from the mean, updated by Welford's method
+	Min   float64
+	Max   float64
+
+	mean float64
+}
+
+func (t *Tally) Add(v float64) {
+	if t.Count == 0 || v < t.Min {
+		t.Min = v
+	}
+
+	if t.Count == 0 || v > t.Max {
+		t.Max = v
+	}
+
+	t.Count++
+	t.Sum += v
+	d := v - t.mean
+	t.mean += d / float64(t.Count)
+	t.M2 += d * (v - t.mean)
+}
+
+func (t *Tally) Mean() float64 {
+	if t.Count == 0 {
+		return 0
+	}
+
+	return t.Sum / float64(t.Count)
+}
+
+// Std is the sample standard deviation
+func (t *Tally) Std() float64 {
+	if t.Count < 2 {
+		return 0
+	}
+
+	return math.Sqrt(t.M2 / float64(t.Count-1))
+}
+
+func (t *Tally) Reset() {
+	*t = Tally{}
+}
+
+// HalfWidth is the half width of the Student-t confidence interval of the mean at the level, e.g. 0.95
+func (t *Tally) HalfWidth(level float64) float64 {
+	if t.Count < 2 {
+		return math.Inf(1)
+	}
+
+	return tQuantile((1+level)/2, t.Count-1) * t.Std() / math.Sqrt(float64(t.Count))
+}
+
+// tQuantile is the quantile of the Student t distribution with df degrees of freedom
+func tQuantile(p float64, df int) float64 {
+	if p < 0.5 {
+		return -tQuantile(1-p, df)
+	}
+
+	// the distribution function grows with x, halve the interval holding the quantile
+	v := float64(df)
+	cdf := func(x float64) float64 {
+		return 1 - 0.5*betaInc(v/(v+x*x), v/2, 0.5)
+	}
+
+	lo, hi := 0.0, 1.0
+	for cdf(hi) < p && hi < 1e12 {
+		hi *= 2
+	}
+	for i := 0; i < 200 && hi-lo > 1e-12*hi; i++ {
+		mid := (lo + hi) / 2
+		if cdf(mid) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return (lo + hi) / 2
+}
+
+// betaInc is the regularized incomplete beta function I_x(a, b)
+func betaInc(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(x, a, b) / a
+	}
+
+	return 1 - front*betaFraction(1-x, b, a)/b
+}
+
+// betaFraction evaluates the continued fraction of the incomplete beta function (Lentz's method)
+func betaFraction(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	f := d
+
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		for _, num := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			f *= d * c
+		}
+
+		if math.Abs(d*c-1) < 1e-15 {
+			break
+		}
+	}
+
+	return f
+}
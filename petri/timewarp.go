@@ -0,0 +1,630 @@
+package petri
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// optimisticProcess runs one object of a Time Warp simulation
+type optimisticProcess struct {
+	obj *Simulator
+
+	sync.Mutex // guards the fields below, they are shared with other processes
+	mailbox    []warpMessage
+	published  float64 // the object won't handle an event or send markers before it
+	closed     bool
+
+	notify chan struct{}
+
+	inputs []*warpInput   // markers received, in the order of delivery
+	saved  []*objectState // states before the events handled since GVT
+	sent   []warpMessage  // markers sent since GVT, anti-messages cancel them
+	lvt    float64        // time of the last event handled
+	nextID int64
+	events int // handled since the last computation of GVT
+	peak   int
+}
+
+// warpMessage is a message of a port in a Time Warp run. The processes keep messages on
+// their way in own mailboxes instead of the one of the port, GVT has to see all of them.
+type warpMessage struct {
+	Message
+	port *Port
+	ID   int64 // numbers the firings an object sent, an anti-message cancels the one with its ID
+}
+
+// deliver puts the markers into the place of the port
+func (m warpMessage) deliver(s *Simulator, now float64) {
+	m.put(s.Places[m.port.Place], now)
+}
+
+// warpInput is a message received by an optimistic object
+type warpInput struct {
+	warpMessage
+	order int  // of the port among the input ports of the object
+	done  bool // delivered, a rollback to its time takes it back
+}
+
+// objectState is an object as it was before an event
+type objectState struct {
+	time      float64 // of the event
+	lvt       float64
+	timeLocal float64
+	random    uint64
+
+	places      []placeState
+	transitions []transitionState
+	arrived     []tokenArrival
+}
+
+type placeState struct {
+	mark        float64
+	mean        float64
+	observedMax float64
+	observedMin float64
+	tokens      []*Token
+	sojourn     Tally
+	age         Tally
+}
+
+type transitionState struct {
+	channels    []Event
+	scheduled   int64
+	buffer      int
+	minTime     float64
+	timeServing float64
+	mean        float64
+	observedMax float64
+	observedMin float64
+	latency     Tally
+	random      uint64
+	delay       interface{} // state of a StatefulDistribution
+}
+
+type tokenArrival struct {
+	token   *Token
+	arrived float64
+}
+
+// TimeWarp runs objects joined by ports in parallel optimistically. An object handles its events as soon
+// as it has them and saves its state before each one, markers that arrive in its past roll
+// it back and anti-messages cancel the markers it sent since then. Global virtual time (GVT),
+// the earliest time an object can still be rolled back to, commits the events before it and
+// frees their states, so memory stays bounded. Streams set with SetStreams and delays
+// implementing StatefulDistribution, like traces, are rolled back as well, so a run gives
+// the same results as a serial run with the same seed. Other delays set with SetDelay or
+// registered distributions must not keep state between samples.
+type TimeWarp struct {
+	Messages     int64 // firings sent through the ports
+	AntiMessages int64
+	Rollbacks    int64
+	RolledBack   int64 // events undone by rollbacks
+	Events       int64 // events handled, undone ones included
+	GVTRounds    int64
+	MaxSaved     int // most states an object kept at once
+
+	Objects []*Simulator
+	Ports   []*Port // between the objects, see Simulator.Connect and Model.ConnectShared
+	EndTime float64
+
+	GVTInterval int     // events an object handles between computations of GVT, 100 by default
+	Window      float64 // how far an object may go ahead of GVT, 0 for no limit
+
+	sync.Mutex
+	processes []*optimisticProcess
+	of        map[*Simulator]*optimisticProcess
+	gvt       float64
+	done      chan struct{} // closed when GVT passes EndTime
+}
+
+type BuildTimeWarp interface {
+	Build([]*Simulator, float64) *TimeWarp
+	Run(context.Context) error
+	GVT() float64
+}
+
+func (w *TimeWarp) Build(objects []*Simulator, endTime float64) *TimeWarp {
+	w.Objects = objects
+	w.Ports = nil
+	w.EndTime = endTime
+	return w
+}
+
+// GVT is the time up to which the run is committed
+func (w *TimeWarp) GVT() float64 {
+	w.Lock()
+	defer w.Unlock()
+	return w.gvt
+}
+
+// Run starts a goroutine per object and waits until GVT passes EndTime
+func (w *TimeWarp) Run(ctx context.Context) error {
+	if w.GVTInterval <= 0 {
+		w.GVTInterval = 100
+	}
+
+	// observers can't take back what rolled back steps added
+	for _, s := range w.Objects {
+		if len(s.observers) > 0 {
+			return fmt.Errorf("object %s has batch means, time warp runs can't roll them back", s.Name)
+		}
+	}
+
+	ports, err := portsOf(w.Objects)
+	if err != nil {
+		return err
+	}
+	w.Ports = ports
+
+	w.processes = nil
+	w.of = make(map[*Simulator]*optimisticProcess)
+	for _, s := range w.Objects {
+		lp := &optimisticProcess{obj: s, notify: make(chan struct{}, 1), lvt: math.Inf(-1)}
+		w.processes = append(w.processes, lp)
+		w.of[s] = lp
+	}
+
+	w.gvt = 0
+	w.done = make(chan struct{})
+
+	errs := make(chan error, len(w.processes))
+	for _, lp := range w.processes {
+		go func(lp *optimisticProcess) {
+			errs <- w.run(ctx, lp)
+		}(lp)
+	}
+
+	var stopped []string
+	for range w.processes {
+		if err := <-errs; err != nil {
+			stopped = append(stopped, err.Error())
+		}
+	}
+
+	for _, lp := range w.processes {
+		if lp.peak > w.MaxSaved {
+			w.MaxSaved = lp.peak
+		}
+	}
+
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		return fmt.Errorf("time warp run stopped: %v; %s", ctx.Err(), strings.Join(stopped, "; "))
+	}
+
+	return nil
+}
+
+func (w *TimeWarp) run(ctx context.Context, lp *optimisticProcess) error {
+	lp.obj.Input()
+
+	for {
+		select {
+		case <-w.done:
+			w.finish(lp)
+			return nil
+		case <-ctx.Done():
+			return w.stopped(lp)
+		default:
+		}
+
+		w.takeMessages(lp)
+		next := lp.nextEvent()
+		lp.Lock()
+		lp.published = next
+		lp.Unlock()
+
+		if next <= w.EndTime && (w.Window <= 0 || next <= w.GVT()+w.Window) {
+			w.handle(lp, next)
+			if lp.events++; lp.events >= w.GVTInterval {
+				lp.events = 0
+				lp.fossilCollect(w.computeGVT())
+			}
+			continue
+		}
+
+		lp.fossilCollect(w.computeGVT())
+		select {
+		case <-lp.notify:
+		case <-w.done:
+		case <-ctx.Done():
+			return w.stopped(lp)
+		}
+	}
+}
+
+func (w *TimeWarp) stopped(lp *optimisticProcess) error {
+	return fmt.Errorf("object %s stopped at time %f, GVT %f", lp.obj.Name, lp.lvt, w.GVT())
+}
+
+// nextEvent is the earliest end of service or arrival of markers not delivered yet
+func (lp *optimisticProcess) nextEvent() float64 {
+	next := lp.obj.Calendar.NextTime()
+	for _, in := range lp.inputs {
+		if !in.done {
+			return math.Min(next, in.Time)
+		}
+	}
+
+	return next
+}
+
+func (w *TimeWarp) handle(lp *optimisticProcess, t float64) {
+	s := lp.obj
+	lp.saved = append(lp.saved, s.save(t, lp.lvt))
+	if len(lp.saved) > lp.peak {
+		lp.peak = len(lp.saved)
+	}
+	lp.lvt = t
+
+	if t > s.TimeLocal {
+		s.MoveTimeLocal(t)
+	}
+	now := s.TimeLocal
+
+	for _, in := range lp.inputs {
+		if in.Time > now {
+			break
+		}
+
+		if !in.done {
+			m := in.warpMessage
+			m.Tokens = copyTokens(m.Tokens)
+			m.deliver(s, now)
+			in.done = true
+		}
+	}
+
+	for tr := s.Calendar.Next(); tr != nil && tr.MinTime <= now; tr = s.Calendar.Next() {
+		tr.ActOut(s.Places)
+		w.send(lp, tr, now)
+	}
+
+	s.Input()
+	atomic.AddInt64(&w.Events, 1)
+}
+
+// send passes the firing of the transition through its ports, the firings are kept to be cancelled on rollback
+func (w *TimeWarp) send(lp *optimisticProcess, tr *Transition, now float64) {
+	for _, p := range lp.obj.OutPorts {
+		if lp.obj.Transitions[p.Transition] != tr {
+			continue
+		}
+
+		lp.nextID++
+		m := warpMessage{Message: Message{Kind: TokenArrival, Time: now, Count: p.Count}, port: p, ID: lp.nextID}
+		if p.source >= 0 {
+			m.Tokens = copyTokens(lp.obj.Places[p.source].takeSent(p.Count))
+		}
+
+		lp.sent = append(lp.sent, m)
+		atomic.AddInt64(&w.Messages, 1)
+		w.post(w.of[p.To], m)
+	}
+}
+
+func (w *TimeWarp) post(to *optimisticProcess, m warpMessage) {
+	to.Lock()
+	if !to.closed {
+		to.mailbox = append(to.mailbox, m)
+	}
+	to.Unlock()
+
+	select {
+	case to.notify <- struct{}{}:
+	default:
+	}
+}
+
+// takeMessages moves the mailbox to the inputs, the object stays published no later
+// than the messages so GVT can't pass them
+func (w *TimeWarp) takeMessages(lp *optimisticProcess) {
+	lp.Lock()
+	messages := lp.mailbox
+	lp.mailbox = nil
+	for _, m := range messages {
+		lp.published = math.Min(lp.published, m.Time)
+	}
+	lp.Unlock()
+
+	for _, m := range messages {
+		if m.Kind == AntiMessage {
+			w.annihilate(lp, m)
+		} else {
+			w.receive(lp, m)
+		}
+	}
+}
+
+func (w *TimeWarp) receive(lp *optimisticProcess, m warpMessage) {
+	in := &warpInput{warpMessage: m}
+	for i, p := range lp.obj.InPorts {
+		if p == m.port {
+			in.order = i
+		}
+	}
+
+	// a straggler: the events since its time are handled without it
+	if m.Time <= lp.lvt {
+		w.rollback(lp, m.Time)
+	}
+
+	k := sort.Search(len(lp.inputs), func(i int) bool {
+		a := lp.inputs[i]
+		if a.Time != in.Time {
+			return a.Time > in.Time
+		}
+		if a.order != in.order {
+			return a.order > in.order
+		}
+		return a.ID > in.ID
+	})
+	lp.inputs = append(lp.inputs, nil)
+	copy(lp.inputs[k+1:], lp.inputs[k:])
+	lp.inputs[k] = in
+}
+
+func (w *TimeWarp) annihilate(lp *optimisticProcess, m warpMessage) {
+	for i, in := range lp.inputs {
+		if in.port != m.port || in.ID != m.ID {
+			continue
+		}
+
+		if in.done {
+			w.rollback(lp, in.Time)
+		}
+		lp.inputs = append(lp.inputs[:i], lp.inputs[i+1:]...)
+		return
+	}
+
+	log.Printf("%s: no markers %d from %s to cancel", lp.obj.Name, m.ID, m.port.From.Name)
+}
+
+// rollback restores the object as it was before the first event at or after t
+// and cancels the markers it sent since
+func (w *TimeWarp) rollback(lp *optimisticProcess, t float64) {
+	k := sort.Search(len(lp.saved), func(i int) bool {
+		return lp.saved[i].time >= t
+	})
+	if k == len(lp.saved) {
+		return
+	}
+
+	st := lp.saved[k]
+	lp.obj.restore(st)
+	lp.lvt = st.lvt
+	atomic.AddInt64(&w.Rollbacks, 1)
+	atomic.AddInt64(&w.RolledBack, int64(len(lp.saved)-k))
+
+	for i := k; i < len(lp.saved); i++ {
+		lp.saved[i] = nil
+	}
+	lp.saved = lp.saved[:k]
+
+	for _, in := range lp.inputs {
+		if in.Time >= t {
+			in.done = false
+		}
+	}
+
+	keep := 0
+	for _, m := range lp.sent {
+		if m.Time < t {
+			lp.sent[keep] = m
+			keep++
+			continue
+		}
+
+		anti := m
+		anti.Tokens = nil
+		anti.Kind = AntiMessage
+		atomic.AddInt64(&w.AntiMessages, 1)
+		w.post(w.of[m.port.To], anti)
+	}
+	lp.sent = lp.sent[:keep]
+}
+
+// computeGVT takes the earliest time published by the objects or carried by messages
+// on their way, no object can go back before it
+func (w *TimeWarp) computeGVT() float64 {
+	w.Lock()
+	defer w.Unlock()
+
+	gvt := math.Inf(1)
+	for _, lp := range w.processes {
+		lp.Lock()
+	}
+	for _, lp := range w.processes {
+		gvt = math.Min(gvt, lp.published)
+		for _, m := range lp.mailbox {
+			gvt = math.Min(gvt, m.Time)
+		}
+	}
+	for _, lp := range w.processes {
+		lp.Unlock()
+	}
+	atomic.AddInt64(&w.GVTRounds, 1)
+
+	if gvt <= w.gvt {
+		return w.gvt
+	}
+	w.gvt = gvt
+
+	if gvt > w.EndTime {
+		select {
+		case <-w.done:
+		default:
+			close(w.done)
+		}
+	}
+
+	// objects held back by the window may go on
+	for _, lp := range w.processes {
+		select {
+		case lp.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return gvt
+}
+
+// fossilCollect drops the states, inputs and sent markers before GVT, no rollback reaches them
+func (lp *optimisticProcess) fossilCollect(gvt float64) {
+	k := sort.Search(len(lp.saved), func(i int) bool {
+		return lp.saved[i].time >= gvt
+	})
+	lp.saved = append(lp.saved[:0], lp.saved[k:]...)
+
+	k = 0
+	for k < len(lp.inputs) && lp.inputs[k].done && lp.inputs[k].Time < gvt {
+		k++
+	}
+	lp.inputs = append(lp.inputs[:0], lp.inputs[k:]...)
+
+	k = 0
+	for k < len(lp.sent) && lp.sent[k].Time < gvt {
+		k++
+	}
+	lp.sent = append(lp.sent[:0], lp.sent[k:]...)
+}
+
+func (w *TimeWarp) finish(lp *optimisticProcess) {
+	s := lp.obj
+	if w.EndTime > s.TimeLocal {
+		s.MoveTimeLocal(w.EndTime)
+	}
+
+	lp.Lock()
+	lp.closed = true
+	lp.mailbox = nil
+	lp.Unlock()
+
+	lp.saved, lp.inputs, lp.sent = nil, nil, nil
+	log.Printf("%s has finished simulation\n", s.Name)
+}
+
+func copyTokens(tokens []*Token) []*Token {
+	var copies []*Token
+	for _, t := range tokens {
+		c := *t
+		copies = append(copies, &c)
+	}
+
+	return copies
+}
+
+// save records the object before the event at time t
+func (s *Simulator) save(t float64, lvt float64) *objectState {
+	st := &objectState{
+		time:        t,
+		lvt:         lvt,
+		timeLocal:   s.TimeLocal,
+		places:      make([]placeState, 0, len(s.Places)),
+		transitions: make([]transitionState, 0, len(s.Transitions)),
+	}
+	st.random, _ = streamState(s.Random)
+
+	for _, p := range s.Places {
+		var tokens []*Token
+		if len(p.Tokens) > 0 {
+			tokens = append(tokens, p.Tokens...)
+		}
+
+		st.places = append(st.places, placeState{
+			mark:        p.Mark,
+			mean:        p.Mean,
+			observedMax: p.ObservedMax,
+			observedMin: p.ObservedMin,
+			tokens:      tokens,
+			sojourn:     p.Sojourn,
+			age:         p.Age,
+		})
+
+		for _, token := range p.Tokens {
+			st.arrived = append(st.arrived, tokenArrival{token: token, arrived: token.Arrived})
+		}
+	}
+
+	for _, tr := range s.Transitions {
+		ts := transitionState{
+			scheduled:   tr.scheduled,
+			buffer:      tr.Buffer,
+			minTime:     tr.MinTime,
+			timeServing: tr.TimeServing,
+			mean:        tr.Mean,
+			observedMax: tr.ObservedMax,
+			observedMin: tr.ObservedMin,
+			latency:     tr.Latency,
+		}
+		ts.random, _ = streamState(tr.Random)
+		if d, ok := tr.delay.(StatefulDistribution); ok {
+			ts.delay = d.State()
+		}
+
+		if len(tr.channels) > 0 {
+			ts.channels = make([]Event, 0, len(tr.channels))
+		}
+		for _, e := range tr.channels {
+			ts.channels = append(ts.channels, *e)
+			for _, c := range e.tokens {
+				st.arrived = append(st.arrived, tokenArrival{token: c.token, arrived: c.token.Arrived})
+			}
+		}
+		st.transitions = append(st.transitions, ts)
+	}
+
+	return st
+}
+
+func (s *Simulator) restore(st *objectState) {
+	s.TimeLocal = st.timeLocal
+	setStreamState(s.Random, st.random)
+
+	for i, p := range s.Places {
+		ps := st.places[i]
+		p.Mark = ps.mark
+		p.Mean = ps.mean
+		p.ObservedMax = ps.observedMax
+		p.ObservedMin = ps.observedMin
+		p.Tokens = append([]*Token(nil), ps.tokens...)
+		p.Sojourn = ps.sojourn
+		p.Age = ps.age
+	}
+
+	for _, a := range st.arrived {
+		a.token.Arrived = a.arrived
+	}
+
+	for i, tr := range s.Transitions {
+		ts := st.transitions[i]
+		tr.channels = nil
+		for k := range ts.channels {
+			e := ts.channels[k]
+			tr.channels = append(tr.channels, &e)
+		}
+		tr.scheduled = ts.scheduled
+		tr.Buffer = ts.buffer
+		tr.MinTime = ts.minTime
+		tr.TimeServing = ts.timeServing
+		tr.Mean = ts.mean
+		tr.ObservedMax = ts.observedMax
+		tr.ObservedMin = ts.observedMin
+		tr.Latency = ts.latency
+		setStreamState(tr.Random, ts.random)
+		if d, ok := tr.delay.(StatefulDistribution); ok && ts.delay != nil {
+			d.SetState(ts.delay)
+		}
+
+		s.enabling.touch(i)
+	}
+
+	s.Calendar.Build(s.Transitions)
+	s.ProcessEventMin()
+}
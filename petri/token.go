@@ -0,0 +1,173 @@
+package petri
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Token is a marker of a coloured place, it keeps its identity while transitions move it
+// through the net and through linked objects
+type Token struct {
+	ID      int64
+	Class   string
+	Created float64 // time the token entered the net
+	Arrived float64 // time the token entered its current place
+	Attrs   map[string]interface{}
+}
+
+// tokenCounter numbers the tokens of a net, or of all nets of a model, so runs of the
+// same model give the same identities
+type tokenCounter struct {
+	last int64
+}
+
+func (c *tokenCounter) next() int64 {
+	return atomic.AddInt64(&c.last, 1)
+}
+
+// numberTokens makes the places count tokens with c and numbers their tokens over again
+func numberTokens(places []*Place, c *tokenCounter) {
+	for _, p := range places {
+		p.ids = c
+		for _, t := range p.Tokens {
+			t.ID = c.next()
+		}
+	}
+}
+
+// newToken makes a token numbered by the counter of the place, a place outside a net counts on its own
+func (p *Place) newToken(class string, currentTime float64) *Token {
+	if p.ids == nil {
+		p.ids = &tokenCounter{}
+	}
+
+	return &Token{
+		ID:      p.ids.next(),
+		Class:   class,
+		Created: currentTime,
+		Arrived: currentTime,
+	}
+}
+
+// carriedToken is a token inside a transition and the place it was taken from
+type carriedToken struct {
+	token *Token
+	from  int
+}
+
+// tokenInbox holds tokens sent to a place by another object
+type tokenInbox struct {
+	sync.Mutex
+	tokens []*Token
+}
+
+// SetColoured turns token records on for the place, the current mark becomes tokens created at time 0
+func (p *Place) SetColoured(c bool) BuildPlace {
+	p.Coloured = c
+	p.Tokens = nil
+	p.inbox = nil
+	if c {
+		p.inbox = &tokenInbox{}
+		for i := 0; i < int(p.Mark); i++ {
+			p.Tokens = append(p.Tokens, p.newToken("", 0))
+		}
+	}
+	p.changed()
+
+	return p
+}
+
+func (p *Place) IsColoured() bool {
+	return p.Coloured
+}
+
+// CountTokens counts tokens of the class, an empty class matches all tokens
+func (p *Place) CountTokens(class string) int {
+	if class == "" {
+		return len(p.Tokens)
+	}
+
+	n := 0
+	for _, t := range p.Tokens {
+		if t.Class == class {
+			n++
+		}
+	}
+
+	return n
+}
+
+// PutTokens stores tokens arriving at the time, the mark is changed by IncrMark
+func (p *Place) PutTokens(tokens []*Token, currentTime float64) {
+	for _, t := range tokens {
+		t.Arrived = currentTime
+		p.Age.Add(currentTime - t.Created)
+	}
+
+	p.Tokens = append(p.Tokens, tokens...)
+	p.changed()
+}
+
+// TakeTokens removes the n oldest tokens of the class and records how long they stayed
+func (p *Place) TakeTokens(n int, class string, currentTime float64) []*Token {
+	var taken []*Token
+	rest := p.Tokens[:0]
+	for _, t := range p.Tokens {
+		if len(taken) < n && (class == "" || t.Class == class) {
+			p.Sojourn.Add(currentTime - t.Arrived)
+			taken = append(taken, t)
+		} else {
+			rest = append(rest, t)
+		}
+	}
+
+	for i := len(rest); i < len(p.Tokens); i++ {
+		p.Tokens[i] = nil
+	}
+	p.Tokens = rest
+	p.changed()
+
+	return taken
+}
+
+// Send parks tokens for a place of another object, they enter it with Receive
+// when the object takes the markers in
+func (p *Place) Send(tokens []*Token) {
+	p.inbox.Lock()
+	p.inbox.tokens = append(p.inbox.tokens, tokens...)
+	p.inbox.Unlock()
+}
+
+// Receive moves n sent tokens into the place, missing ones are created
+func (p *Place) Receive(n int, currentTime float64) {
+	tokens := p.takeSent(n)
+	for len(tokens) < n {
+		tokens = append(tokens, p.newToken("", currentTime))
+	}
+
+	p.PutTokens(tokens, currentTime)
+}
+
+// takeSent takes up to n of the tokens sent to the place
+func (p *Place) takeSent(n int) []*Token {
+	if p.inbox == nil {
+		return nil
+	}
+
+	p.inbox.Lock()
+	defer p.inbox.Unlock()
+	k := n
+	if k > len(p.inbox.tokens) {
+		k = len(p.inbox.tokens)
+	}
+	tokens := p.inbox.tokens[:k:k]
+	p.inbox.tokens = p.inbox.tokens[k:]
+	return tokens
+}
+
+// SetColoured switches token records for all places of the net
+func (n *Net) SetColoured(c bool) {
+	for _, p := range n.Places {
+		p.SetColoured(c)
+	}
+}
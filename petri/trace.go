@@ -0,0 +1,191 @@
+package petri
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Trace replays recorded delays instead of sampling them, it is plugged into a
+// transition with SetDelay. When the records are over the transition isn't enabled
+// any more unless Cycle is set.
+type Trace struct {
+	sync.Mutex
+	Delays []float64
+	Cycle  bool
+
+	// the path the trace was opened with, SaveNet writes it relative to the net file
+	Path       string
+	Column     int
+	Timestamps bool
+
+	pos int
+}
+
+func (t *Trace) Sample(RandomStream) float64 {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.pos >= len(t.Delays) {
+		if !t.Cycle || len(t.Delays) == 0 {
+			return math.MaxFloat64
+		}
+
+		t.pos = 0
+	}
+
+	d := t.Delays[t.pos]
+	t.pos++
+	return d
+}
+
+// Exhausted tells that all records were replayed and the trace doesn't cycle, see FiniteDistribution
+func (t *Trace) Exhausted() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.pos >= len(t.Delays) && (!t.Cycle || len(t.Delays) == 0)
+}
+
+// MinDelay is the shortest recorded delay, see BoundedDistribution
+func (t *Trace) MinDelay() float64 {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.Delays) == 0 {
+		return 0
+	}
+
+	min := math.Inf(1)
+	for _, d := range t.Delays {
+		min = math.Min(min, d)
+	}
+	return min
+}
+
+// State is the position in the trace, see StatefulDistribution
+func (t *Trace) State() interface{} {
+	t.Lock()
+	defer t.Unlock()
+	return t.pos
+}
+
+func (t *Trace) SetState(state interface{}) {
+	t.Lock()
+	t.pos = state.(int)
+	t.Unlock()
+}
+
+// clone is a trace with the same records at the same position that is replayed on its own
+func (t *Trace) clone() *Trace {
+	t.Lock()
+	defer t.Unlock()
+	return &Trace{Delays: t.Delays, Cycle: t.Cycle, Path: t.Path, Column: t.Column, Timestamps: t.Timestamps, pos: t.pos}
+}
+
+// Rewind starts the trace over, e.g. for the next replication
+func (t *Trace) Rewind() {
+	t.Lock()
+	t.pos = 0
+	t.Unlock()
+}
+
+// ReadTrace reads one column of a CSV, a first row without any number is a header and
+// skipped. With timestamps
+// the column holds arrival times counted from the start of the run, otherwise
+// inter-arrival times.
+func ReadTrace(r io.Reader, column int, timestamps bool) (*Trace, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var values []float64
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read trace: %v", err)
+		}
+
+		if column >= len(record) {
+			return nil, fmt.Errorf("read trace: row %d has no column %d", row+1, column)
+		}
+
+		if row == 0 && isHeader(record) {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(record[column]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("read trace: row %d: %v", row+1, err)
+		}
+
+		values = append(values, v)
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("read trace: no records")
+	}
+
+	delays := values
+	if timestamps {
+		delays = make([]float64, len(values))
+		prev := 0.0
+		for i, v := range values {
+			delays[i] = v - prev
+			prev = v
+		}
+	}
+
+	for i, d := range delays {
+		if d < 0 || math.IsNaN(d) {
+			if timestamps {
+				return nil, fmt.Errorf("read trace: timestamp %d goes back in time", i+1)
+			}
+			return nil, fmt.Errorf("read trace: negative inter-arrival time %f in record %d", d, i+1)
+		}
+	}
+
+	return &Trace{Delays: delays, Column: column, Timestamps: timestamps}, nil
+}
+
+func LoadTrace(path string, column int, timestamps bool) (*Trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := ReadTrace(f, column, timestamps)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	t.Path = path
+	return t, nil
+}
+
+// CreateNetGeneratorFromTrace is the generator of CreateNetGenerator with arrivals taken from a trace
+func CreateNetGeneratorFromTrace(timeModeling float64, trace Distribution, counter *GlobalCounter) Net {
+	net := CreateNetGenerator(timeModeling, 0, "", counter)
+	net.Transitions[0].SetDelay(trace)
+	return net
+}
+
+// isHeader tells whether none of the fields of the record is a number
+func isHeader(record []string) bool {
+	for _, field := range record {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
+			return false
+		}
+	}
+
+	return true
+}
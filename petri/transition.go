@@ -1,9 +1,11 @@
 package petri
 
 import (
+	"container/heap"
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strings"
 )
 
@@ -17,26 +19,53 @@ type Transition struct {
 	TimeServing    float64
 	AvgTimeServing float64
 	AvgDeviation   float64
-	Distribution   string // possible names: unif, exp, norm
+	Distribution   string             // name of a registered distribution, see RegisteredDistributions
+	Params         map[string]float64 // named parameters of the distribution besides mean and deviation
+	Random         RandomStream
+
+	delay    Distribution
+	ownDelay bool // delay was set with SetDelay
+
+	Guard       Guard      // checked together with the arcs, nil allows firing
+	ActInHooks  []FireHook // called when markers enter the transition
+	ActOutHooks []FireHook // called when markers leave the transition
+
+	Fired   []*Token // tokens of coloured places taken or released by the current firing
+	Latency Tally    // time since creation of tokens that leave the net through the transition
+
+	channels  eventHeap      // busy channels ordered by the end of service
+	scheduled int64          // events scheduled so far, orders events of equal time
+	entry     *calendarEntry // place in the calendar of the simulator
+
+	enabling      *enablingSet
+	enablingIndex int
 
-	Timeout               []float64
 	InPlaces              []int
 	InPlacesWithInfo      []int
 	CounterInPlaces       []int
 	CounterPlacesWithInfo []int
+	InPlacesWithInhibitor []int
+	CounterInhibitor      []int
+	InPlacesWithReset     []int
 	OutPlaces             []int
 	CounterOutPlaces      []int
 
-	IMultiChannel int
-	Number        int
-	Mean          float64
-	ObservedMin   float64
-	ObservedMax   float64
+	// token classes of the links, empty for any
+	ClassInPlaces  []string
+	ClassInfo      []string
+	ClassInhibitor []string
+	ClassOutPlaces []string
+
+	Number      int
+	Mean        float64
+	ObservedMin float64
+	ObservedMax float64
 }
 
 type BuildTransition interface {
 	SetTimeModeling(float64) BuildTransition
 	SetMean(float64) BuildTransition
+	ResetStatistics() BuildTransition
 	SetPriority(int) BuildTransition
 	SetProbability(float64) BuildTransition
 	SetBuffer(int) BuildTransition
@@ -46,7 +75,15 @@ type BuildTransition interface {
 	SetName(string) BuildTransition
 	SetIMultiChannel(int) BuildTransition
 	SetNumber(int) BuildTransition
-
+	SetRandom(RandomStream) BuildTransition
+	SetParam(string, float64) BuildTransition
+	SetDelay(Distribution) BuildTransition
+	SetGuard(Guard) BuildTransition
+	AddActInHook(FireHook) BuildTransition
+	AddActOutHook(FireHook) BuildTransition
+
+	DistributionParams() map[string]float64
+	DistributionError() error
 	GenerateTimeServing() float64
 
 	AddInPlace(int) BuildTransition
@@ -60,6 +97,11 @@ type BuildTransition interface {
 	ActOut([]*Place) BuildTransition
 	InitNext(*GlobalCounter) BuildTransition
 	MinEvent() BuildTransition
+	Timeout() float64
+	IMultiChannel() int
+	Timeouts() []float64
+	Events() []*Event
+	Cancel(*Event, []*Place) BuildTransition
 
 	Print()
 
@@ -73,7 +115,6 @@ func (t *Transition) Build(transitionName string, timeDelay float64, probability
 	t.TimeServing = t.AvgTimeServing
 	t.Buffer = 0
 	t.MinTime = math.MaxFloat64
-	t.IMultiChannel = 0
 	t.Mean = 0
 	t.TimeModeling = math.MaxFloat64 - 1
 	t.ObservedMax = float64(t.Buffer)
@@ -83,7 +124,6 @@ func (t *Transition) Build(transitionName string, timeDelay float64, probability
 	t.Distribution = ""
 	t.Number = c.Transition
 	c.Transition++
-	t.Timeout = append(t.Timeout, math.MaxFloat64)
 	t.MinEvent()
 
 	return t
@@ -104,6 +144,15 @@ func (t *Transition) SetMean(m float64) BuildTransition {
 	return t
 }
 
+// ResetStatistics starts the mean, the observed range and the latency over from now
+func (t *Transition) ResetStatistics() BuildTransition {
+	t.Mean = 0
+	t.ObservedMax = float64(t.Buffer)
+	t.ObservedMin = float64(t.Buffer)
+	t.Latency.Reset()
+	return t
+}
+
 func (t *Transition) SetPriority(p int) BuildTransition {
 	t.Priority = p
 	return t
@@ -111,6 +160,7 @@ func (t *Transition) SetPriority(p int) BuildTransition {
 
 func (t *Transition) SetProbability(p float64) BuildTransition {
 	t.Probability = p
+	t.touch()
 	return t
 }
 
@@ -123,42 +173,118 @@ func (t *Transition) SetDistribution(d string, param float64) BuildTransition {
 	t.Distribution = d
 	t.AvgTimeServing = param
 	t.TimeServing = t.AvgTimeServing
+	t.resetDelay()
+
+	if d != "" && !knownDistribution(d) {
+		log.Printf("transition %s: unknown distribution %q, it serves in the mean time %f", t.Name, d, param)
+	}
 	return t
 }
 
 func (t *Transition) SetAvgTimeServing(v float64) BuildTransition {
 	t.AvgTimeServing = v
 	t.TimeServing = t.AvgTimeServing
+	t.resetDelay()
 	return t
 }
 
 func (t *Transition) SetDeviation(v float64) BuildTransition {
 	t.AvgDeviation = v
+	t.resetDelay()
+	return t
+}
+
+func (t *Transition) SetParam(name string, v float64) BuildTransition {
+	if t.Params == nil {
+		t.Params = make(map[string]float64)
+	}
+
+	t.Params[strings.ToLower(name)] = v
+	t.resetDelay()
 	return t
 }
 
-func (t *Transition) SetIMultiChannel(v int) BuildTransition {
-	t.IMultiChannel = v
+// SetDelay overrides the named distribution with a ready one
+func (t *Transition) SetDelay(d Distribution) BuildTransition {
+	t.delay = d
+	t.ownDelay = d != nil
 	return t
 }
 
+func (t *Transition) resetDelay() {
+	t.delay = nil
+	t.ownDelay = false
+}
+
+// DistributionError tells why the named distribution can't be created with the parameters
+// set so far, such a transition serves in the mean time. Net.Build logs it and Validate
+// reports it, SetDistribution logs unknown names at once.
+func (t *Transition) DistributionError() error {
+	if t.ownDelay || t.Distribution == "" {
+		return nil
+	}
+
+	_, err := CreateDistribution(t.Distribution, t.DistributionParams())
+	return err
+}
+
+func (t *Transition) SetGuard(g Guard) BuildTransition {
+	t.Guard = g
+	if t.enabling != nil {
+		t.enabling.guard(t.enablingIndex, g != nil)
+	}
+	return t
+}
+
+func (t *Transition) AddActInHook(h FireHook) BuildTransition {
+	t.ActInHooks = append(t.ActInHooks, h)
+	return t
+}
+
+func (t *Transition) AddActOutHook(h FireHook) BuildTransition {
+	t.ActOutHooks = append(t.ActOutHooks, h)
+	return t
+}
+
+func (t *Transition) DistributionParams() map[string]float64 {
+	params := map[string]float64{
+		"mean":      t.AvgTimeServing,
+		"deviation": t.AvgDeviation,
+	}
+
+	for k, v := range t.Params {
+		params[k] = v
+	}
+
+	return params
+}
+
 func (t *Transition) SetNumber(v int) BuildTransition {
 	t.Number = v
 	return t
 }
 
+func (t *Transition) SetRandom(r RandomStream) BuildTransition {
+	t.Random = r
+	return t
+}
+
 func (t *Transition) GenerateTimeServing() float64 {
-	if t.Distribution != "" {
-		switch strings.ToLower(t.Distribution) {
-		case "exp":
-			t.TimeServing = Exp(t.AvgTimeServing)
-			break
-		case "unif":
-			t.TimeServing = Uniform(t.AvgTimeServing-t.AvgDeviation, t.AvgTimeServing+t.AvgDeviation)
-			break
-		case "norm":
-			t.TimeServing = Normal(t.AvgTimeServing, t.AvgDeviation)
-			break
+	if t.delay == nil && t.Distribution != "" {
+		d, err := CreateDistribution(t.Distribution, t.DistributionParams())
+		if err != nil {
+			log.Printf("transition %s: %v, it serves in the mean time %f", t.Name, err, t.AvgTimeServing)
+			mean := t.AvgTimeServing
+			d = DistributionFunc(func(RandomStream) float64 { return mean })
+		}
+
+		t.delay = d
+	}
+
+	if t.delay != nil {
+		t.TimeServing = t.delay.Sample(streamOrGlobal(t.Random))
+		if t.exhausted() {
+			log.Printf("transition %s replayed all its delays, it won't be enabled again", t.Name)
 		}
 	} else {
 		t.TimeServing = t.AvgTimeServing
@@ -167,13 +293,48 @@ func (t *Transition) GenerateTimeServing() float64 {
 	return t.TimeServing
 }
 
-func (t *Transition) SetName(n string) BuildTransition {
-	t.Name = n
-	return t
+// exhausted tells that the delay has no samples left, see FiniteDistribution
+func (t *Transition) exhausted() bool {
+	d, ok := t.delay.(FiniteDistribution)
+	return ok && d.Exhausted()
 }
 
-func (t *Transition) setMultiChannel(m int) BuildTransition {
-	t.IMultiChannel = m
+// MinDelay is a lower bound of the delays the transition samples, 0 when it isn't known
+// like for exp or norm with a deviation
+func (t *Transition) MinDelay() float64 {
+	if t.ownDelay {
+		if b, ok := t.delay.(BoundedDistribution); ok {
+			return math.Max(b.MinDelay(), 0)
+		}
+		return 0
+	}
+
+	if t.Distribution == "" {
+		return math.Max(t.AvgTimeServing, 0)
+	}
+
+	p := t.DistributionParams()
+	min := 0.0
+	switch strings.ToLower(t.Distribution) {
+	case "const", "det":
+		min, _ = param(p, "value", p["mean"])
+	case "unif":
+		min, _ = param(p, "min", p["mean"]-p["deviation"])
+	case "norm":
+		if p["deviation"] == 0 {
+			min = p["mean"]
+		}
+	case "truncnorm", "triang":
+		min = p["min"]
+	case "empiric":
+		min = p["x0"]
+	}
+
+	return math.Max(min, 0)
+}
+
+func (t *Transition) SetName(n string) BuildTransition {
+	t.Name = n
 	return t
 }
 
@@ -195,22 +356,36 @@ func (t *Transition) AddOutPlace(n int) BuildTransition {
 func (t *Transition) CreateInPlaces(places []*Place, links []*Linker) BuildTransition {
 	t.InPlacesWithInfo = []int{}
 	t.CounterPlacesWithInfo = []int{}
+	t.InPlacesWithInhibitor = []int{}
+	t.CounterInhibitor = []int{}
+	t.InPlacesWithReset = []int{}
 	t.InPlaces = []int{}
 	t.CounterInPlaces = []int{}
+	t.ClassInPlaces = []string{}
+	t.ClassInfo = []string{}
+	t.ClassInhibitor = []string{}
 
 	for i := 0; i < len(links); i++ {
 		if links[i].CounterTransitions == t.Number {
-			if links[i].IsInfo() {
+			if links[i].IsInhibitor() {
+				t.InPlacesWithInhibitor = append(t.InPlacesWithInhibitor, links[i].GetCounterPlaces())
+				t.CounterInhibitor = append(t.CounterInhibitor, links[i].GetQuantity())
+				t.ClassInhibitor = append(t.ClassInhibitor, links[i].GetClass())
+			} else if links[i].IsReset() {
+				t.InPlacesWithReset = append(t.InPlacesWithReset, links[i].GetCounterPlaces())
+			} else if links[i].IsInfo() {
 				t.InPlacesWithInfo = append(t.InPlacesWithInfo, links[i].GetCounterPlaces())
 				t.CounterPlacesWithInfo = append(t.CounterPlacesWithInfo, links[i].GetQuantity())
+				t.ClassInfo = append(t.ClassInfo, links[i].GetClass())
 			} else {
 				t.InPlaces = append(t.InPlaces, links[i].GetCounterPlaces())
 				t.CounterInPlaces = append(t.CounterInPlaces, links[i].GetQuantity())
+				t.ClassInPlaces = append(t.ClassInPlaces, links[i].GetClass())
 			}
 		}
 	}
 
-	if len(t.InPlaces) == 0 {
+	if len(t.InPlaces) == 0 && len(t.InPlacesWithInfo) == 0 && len(t.InPlacesWithInhibitor) == 0 {
 		log.Println(fmt.Errorf("transition %s hasn't input positions", t.Name))
 	}
 
@@ -220,11 +395,13 @@ func (t *Transition) CreateInPlaces(places []*Place, links []*Linker) BuildTrans
 func (t *Transition) CreateOutPlaces(places []*Place, links []*Linker) BuildTransition {
 	t.OutPlaces = []int{}
 	t.CounterOutPlaces = []int{}
+	t.ClassOutPlaces = []string{}
 
 	for i := 0; i < len(links); i++ {
 		if links[i].CounterTransitions == t.Number {
 			t.OutPlaces = append(t.OutPlaces, links[i].GetCounterPlaces())
 			t.CounterOutPlaces = append(t.CounterOutPlaces, links[i].GetQuantity())
+			t.ClassOutPlaces = append(t.ClassOutPlaces, links[i].GetClass())
 		}
 	}
 
@@ -236,43 +413,84 @@ func (t *Transition) CreateOutPlaces(places []*Place, links []*Linker) BuildTran
 }
 
 func (t *Transition) Condition(places []*Place) bool {
+	if t.exhausted() {
+		return false
+	}
+
 	var a = true
 	var b = true
+	var c = true
 
 	for i := 0; i < len(t.InPlaces); i++ {
-		if places[t.InPlaces[i]].GetMark() < float64(t.CounterInPlaces[i]) {
+		if markOfClass(places[t.InPlaces[i]], classAt(t.ClassInPlaces, i)) < float64(t.CounterInPlaces[i]) {
 			a = false
 			break
 		}
 	}
 
 	for i := 0; i < len(t.InPlacesWithInfo); i++ {
-		if places[t.InPlacesWithInfo[i]].GetMark() < float64(t.CounterPlacesWithInfo[i]) {
+		if markOfClass(places[t.InPlacesWithInfo[i]], classAt(t.ClassInfo, i)) < float64(t.CounterPlacesWithInfo[i]) {
 			b = false
 			break
 		}
 	}
 
-	return a == true && b == true
+	for i := 0; i < len(t.InPlacesWithInhibitor); i++ {
+		if markOfClass(places[t.InPlacesWithInhibitor[i]], classAt(t.ClassInhibitor, i)) >= float64(t.CounterInhibitor[i]) {
+			c = false
+			break
+		}
+	}
+
+	if a && b && c && t.Guard != nil {
+		return t.Guard(places)
+	}
+
+	return a == true && b == true && c == true
 }
 
 func (t *Transition) ActIn(places []*Place, currentTime float64) BuildTransition {
 	if t.Condition(places) {
+		var carried []carriedToken
+		t.Fired = nil
 		for i := 0; i < len(t.InPlaces); i++ {
-			places[t.InPlaces[i]].DecrMark(float64(t.CounterInPlaces[i]))
+			p := places[t.InPlaces[i]]
+			p.DecrMark(float64(t.CounterInPlaces[i]))
+			if p.IsColoured() {
+				for _, token := range p.TakeTokens(t.CounterInPlaces[i], classAt(t.ClassInPlaces, i), currentTime) {
+					carried = append(carried, carriedToken{token: token, from: t.InPlaces[i]})
+					t.Fired = append(t.Fired, token)
+				}
+			}
 		}
 
-		if t.Buffer == 0 {
-			t.Timeout[0] = currentTime + t.TimeServing
-		} else {
-			t.Timeout = append(t.Timeout, currentTime+t.TimeServing)
+		e := &Event{Transition: t, tokens: carried}
+		for i := 0; i < len(t.InPlacesWithReset); i++ {
+			p := places[t.InPlacesWithReset[i]]
+			e.reset = append(e.reset, p.GetMark())
+			p.DecrMark(p.GetMark())
+
+			var taken []*Token
+			if p.IsColoured() {
+				taken = p.TakeTokens(len(p.Tokens), "", currentTime)
+			}
+			e.resetTokens = append(e.resetTokens, taken)
 		}
 
+		t.GenerateTimeServing()
+		t.scheduled++
+		e.Time, e.seq = currentTime+t.TimeServing, t.scheduled
+		heap.Push(&t.channels, e)
+
 		t.Buffer++
 		if t.ObservedMax < float64(t.Buffer) {
 			t.ObservedMax = float64(t.Buffer)
 		}
 
+		for _, h := range t.ActInHooks {
+			h(t, places, currentTime)
+		}
+
 		t.MinEvent()
 	} else {
 		log.Println("Condition not true")
@@ -283,57 +501,239 @@ func (t *Transition) ActIn(places []*Place, currentTime float64) BuildTransition
 
 func (t *Transition) ActOut(places []*Place) BuildTransition {
 	if t.Buffer > 0 {
+		e := heap.Pop(&t.channels).(*Event)
+		currentTime := e.Time
+
+		tokens := t.routeTokens(places, e.tokens, currentTime)
 		for i := 0; i < len(t.OutPlaces); i++ {
-			if !places[t.OutPlaces[i]].IsExternal() {
-				places[t.OutPlaces[i]].IncrMark(float64(t.CounterOutPlaces[i]))
+			p := places[t.OutPlaces[i]]
+			if !p.IsExternal() {
+				p.IncrMark(float64(t.CounterOutPlaces[i]))
+				if p.IsColoured() {
+					p.PutTokens(tokens[i], currentTime)
+				}
+			} else if p.IsColoured() {
+				p.Send(tokens[i])
 			}
 		}
 
-		if t.IMultiChannel == 0 && len(t.Timeout) == 1 {
-			t.Timeout[0] = math.MaxFloat64
-		} else {
-			t.Timeout = append(t.Timeout[:t.IMultiChannel], t.Timeout[t.IMultiChannel+1:]...)
-		}
-
 		t.Buffer--
 		if t.ObservedMin > float64(t.Buffer) {
 			t.ObservedMin = float64(t.Buffer)
 		}
 
+		for _, h := range t.ActOutHooks {
+			h(t, places, currentTime)
+		}
+
+		t.MinEvent()
 	}
 
 	return t
 }
 
-func (t *Transition) MinEvent() BuildTransition {
-	var minTime = math.MaxFloat64
-	if len(t.Timeout) > 0 {
-		for i := 0; i < len(t.Timeout); i++ {
-			if t.Timeout[i] < minTime {
-				minTime = t.Timeout[i]
-				t.IMultiChannel = i
+// routeTokens hands carried tokens to coloured output places: a token goes back to
+// the place it came from first (free channels of a server), then to any output
+// that takes its class. Outputs left short get new tokens, tokens left over leave the net.
+func (t *Transition) routeTokens(places []*Place, carried []carriedToken, currentTime float64) [][]*Token {
+	out := make([][]*Token, len(t.OutPlaces))
+	used := make([]bool, len(carried))
+	t.Fired = nil
+
+	take := func(back bool) {
+		for i, n := range t.OutPlaces {
+			if !places[n].IsColoured() {
+				continue
 			}
+
+			class := classAt(t.ClassOutPlaces, i)
+			for j, c := range carried {
+				if len(out[i]) >= t.CounterOutPlaces[i] {
+					break
+				}
+
+				if used[j] || (back && c.from != n) || (class != "" && c.token.Class != class) {
+					continue
+				}
+
+				used[j] = true
+				out[i] = append(out[i], c.token)
+			}
+		}
+	}
+	take(true)
+	take(false)
+
+	for i, n := range t.OutPlaces {
+		if !places[n].IsColoured() {
+			continue
+		}
+
+		for len(out[i]) < t.CounterOutPlaces[i] {
+			out[i] = append(out[i], places[n].newToken(classAt(t.ClassOutPlaces, i), currentTime))
+		}
+
+		t.Fired = append(t.Fired, out[i]...)
+	}
+
+	for j, c := range carried {
+		if !used[j] {
+			t.Latency.Add(currentTime - c.token.Created)
+			t.Fired = append(t.Fired, c.token)
+		}
+	}
+
+	return out
+}
+
+func classAt(classes []string, i int) string {
+	if i < len(classes) {
+		return classes[i]
+	}
+
+	return ""
+}
+
+// markOfClass is the mark of the place or, for coloured places and a class, the number of tokens of the class
+func markOfClass(p *Place, class string) float64 {
+	if class != "" && p.IsColoured() {
+		return float64(p.CountTokens(class))
+	}
+
+	return p.GetMark()
+}
+
+// MinEvent takes the closest end of service from the busy channels and moves
+// the transition in the calendar of its simulator
+func (t *Transition) MinEvent() BuildTransition {
+	minTime := math.MaxFloat64
+	if len(t.channels) > 0 {
+		minTime = t.channels[0].Time
+	}
+
+	if minTime != t.MinTime {
+		t.MinTime = minTime
+		if t.entry != nil {
+			t.entry.calendar.fix(t.entry)
 		}
 	}
 
 	return t
 }
 
+// Timeouts lists the ends of service of busy channels in order
+func (t *Transition) Timeouts() []float64 {
+	events := append(eventHeap(nil), t.channels...)
+	sort.Slice(events, func(i, j int) bool {
+		return events.Less(i, j)
+	})
+
+	var times []float64
+	for _, e := range events {
+		times = append(times, e.Time)
+	}
+
+	return times
+}
+
+// Timeout is the closest end of service, math.MaxFloat64 when all channels are free.
+//
+// Deprecated: busy channels are kept in a heap now, see Timeouts and Events.
+func (t *Transition) Timeout() float64 {
+	if len(t.channels) == 0 {
+		return math.MaxFloat64
+	}
+
+	return t.channels[0].Time
+}
+
+// IMultiChannel is the index in Events of the channel that ends first, the heap keeps it at 0.
+//
+// Deprecated: ActOut always ends the channel with the closest end of service.
+func (t *Transition) IMultiChannel() int {
+	return 0
+}
+
+// SetIMultiChannel is kept for old callers, the index is ignored.
+//
+// Deprecated: ActOut always ends the channel with the closest end of service.
+func (t *Transition) SetIMultiChannel(int) BuildTransition {
+	return t
+}
+
+// Cancel drops a service in progress, markers it took go back to the input places and
+// markers reset arcs removed to theirs. What ActIn hooks did isn't undone.
+func (t *Transition) Cancel(e *Event, places []*Place) BuildTransition {
+	if e.Transition != t || !e.Scheduled() {
+		return t
+	}
+
+	heap.Remove(&t.channels, e.index)
+	for i := len(e.reset) - 1; i >= 0; i-- {
+		p := places[t.InPlacesWithReset[i]]
+		p.IncrMark(e.reset[i])
+		if len(e.resetTokens[i]) > 0 {
+			p.Tokens = append(append([]*Token(nil), e.resetTokens[i]...), p.Tokens...)
+			p.changed()
+		}
+	}
+
+	for i := 0; i < len(t.InPlaces); i++ {
+		places[t.InPlaces[i]].IncrMark(float64(t.CounterInPlaces[i]))
+	}
+
+	// tokens go back to the head of the queue they left
+	for i := len(e.tokens) - 1; i >= 0; i-- {
+		p := places[e.tokens[i].from]
+		p.Tokens = append([]*Token{e.tokens[i].token}, p.Tokens...)
+		p.changed()
+	}
+
+	t.Buffer--
+	if t.ObservedMin > float64(t.Buffer) {
+		t.ObservedMin = float64(t.Buffer)
+	}
+
+	t.MinEvent()
+	return t
+}
+
+// Events lists the busy channels, e.g. to cancel one of them
+func (t *Transition) Events() []*Event {
+	return append([]*Event(nil), t.channels...)
+}
+
 func (t *Transition) Print() {
-	for i := 0; i < len(t.Timeout); i++ {
-		log.Printf("%f %s\n", t.Timeout[i], t.Name)
+	for _, v := range t.Timeouts() {
+		log.Printf("%f %s\n", v, t.Name)
 	}
 }
 
 func (t *Transition) Clone() BuildTransition {
 	var n Transition
 	n = *t
-	n.Timeout = t.Timeout[:]
 	n.InPlaces = t.InPlaces[:]
 	n.InPlacesWithInfo = t.InPlacesWithInfo[:]
 	n.CounterInPlaces = t.CounterInPlaces[:]
 	n.CounterPlacesWithInfo = t.CounterPlacesWithInfo[:]
+	n.InPlacesWithInhibitor = t.InPlacesWithInhibitor[:]
+	n.CounterInhibitor = t.CounterInhibitor[:]
+	n.InPlacesWithReset = t.InPlacesWithReset[:]
 	n.OutPlaces = t.OutPlaces[:]
 	n.CounterOutPlaces = t.CounterOutPlaces[:]
+	n.ActInHooks = t.ActInHooks[:]
+	n.ActOutHooks = t.ActOutHooks[:]
+	n.ClassInPlaces = t.ClassInPlaces[:]
+	n.ClassInfo = t.ClassInfo[:]
+	n.ClassInhibitor = t.ClassInhibitor[:]
+	n.ClassOutPlaces = t.ClassOutPlaces[:]
+	n.channels = nil
+	for _, e := range t.channels {
+		c := *e
+		c.Transition = &n
+		n.channels = append(n.channels, &c)
+	}
+	n.entry = nil
+	n.enabling = nil
 	return &n
 }
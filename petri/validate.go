@@ -0,0 +1,261 @@
+package petri
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// Problem is a single finding of Net.Validate, Place, Transition and Link
+// point to the offending element when there is one
+type Problem struct {
+	Severity   Severity
+	Place      string
+	Transition string
+	Link       *Linker
+	Message    string
+}
+
+func (p Problem) String() string {
+	var where []string
+	if p.Place != "" {
+		where = append(where, "place "+p.Place)
+	}
+
+	if p.Transition != "" {
+		where = append(where, "transition "+p.Transition)
+	}
+
+	if p.Link != nil {
+		where = append(where, fmt.Sprintf("link %s#%d", p.Link.Label, p.Link.Number))
+	}
+
+	if len(where) == 0 {
+		return fmt.Sprintf("%s: %s", p.Severity, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", p.Severity, strings.Join(where, ", "), p.Message)
+}
+
+type Problems []Problem
+
+func (ps Problems) Errors() Problems {
+	return ps.filter(SeverityError)
+}
+
+func (ps Problems) Warnings() Problems {
+	return ps.filter(SeverityWarning)
+}
+
+func (ps Problems) filter(s Severity) Problems {
+	var res Problems
+	for _, p := range ps {
+		if p.Severity == s {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+func (ps Problems) HasErrors() bool {
+	return len(ps.Errors()) > 0
+}
+
+func (ps Problems) String() string {
+	var lines []string
+	for _, p := range ps {
+		lines = append(lines, p.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the structure of a built net
+func (n *Net) Validate() Problems {
+	var ps Problems
+
+	add := func(s Severity, place string, transition string, link *Linker, format string, args ...interface{}) {
+		ps = append(ps, Problem{
+			Severity:   s,
+			Place:      place,
+			Transition: transition,
+			Link:       link,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	places := make(map[string]bool)
+	for _, p := range n.Places {
+		if places[p.Name] {
+			add(SeverityError, p.Name, "", nil, "duplicate place name")
+		}
+		places[p.Name] = true
+
+		if p.Mark < 0 || math.IsNaN(p.Mark) {
+			add(SeverityError, p.Name, "", nil, "invalid mark %f", p.Mark)
+		}
+	}
+
+	transitions := make(map[string]bool)
+	numbers := make(map[int]*Transition)
+	for _, t := range n.Transitions {
+		if transitions[t.Name] {
+			add(SeverityError, "", t.Name, nil, "duplicate transition name")
+		}
+		transitions[t.Name] = true
+
+		if other, ok := numbers[t.Number]; ok {
+			add(SeverityError, "", t.Name, nil, "same number %d as transition %s", t.Number, other.Name)
+		}
+		numbers[t.Number] = t
+
+		if t.AvgTimeServing < 0 || t.AvgDeviation < 0 {
+			add(SeverityError, "", t.Name, nil, "negative time parameters")
+		}
+
+		if err := t.DistributionError(); err != nil {
+			add(SeverityError, "", t.Name, nil, "%v", err)
+		}
+
+		if t.Probability < 0 {
+			add(SeverityError, "", t.Name, nil, "negative probability %f", t.Probability)
+		} else if t.Probability == 0 {
+			add(SeverityWarning, "", t.Name, nil, "zero probability, transition never fires")
+		} else if t.Probability > 1 {
+			add(SeverityWarning, "", t.Name, nil, "probability %f is greater than 1", t.Probability)
+		}
+	}
+
+	checkLinks := func(links []*Linker) {
+		for _, l := range links {
+			if l.CounterPlaces < 0 || l.CounterPlaces >= len(n.Places) {
+				add(SeverityError, l.NamePlace, l.NameTransition, l, "place index %d out of range [0, %d)", l.CounterPlaces, len(n.Places))
+			} else if n.Places[l.CounterPlaces].Name != l.NamePlace {
+				add(SeverityWarning, l.NamePlace, l.NameTransition, l, "place index %d points to place %s", l.CounterPlaces, n.Places[l.CounterPlaces].Name)
+			} else if l.Class != "" && !n.Places[l.CounterPlaces].IsColoured() {
+				add(SeverityWarning, l.NamePlace, l.NameTransition, l, "class %s is ignored, the place isn't coloured", l.Class)
+			}
+
+			if _, ok := numbers[l.CounterTransitions]; !ok {
+				add(SeverityError, l.NamePlace, l.NameTransition, l, "no transition with number %d", l.CounterTransitions)
+			}
+
+			if l.KVariant <= 0 && !l.Reset {
+				add(SeverityError, l.NamePlace, l.NameTransition, l, "weight %d is not positive", l.KVariant)
+			}
+
+			if l.Label == `o` && (l.Info || l.Inhibitor || l.Reset) {
+				add(SeverityError, l.NamePlace, l.NameTransition, l, "output link can't be info, inhibitor or reset")
+			}
+
+			if l.Inhibitor && l.Reset {
+				add(SeverityError, l.NamePlace, l.NameTransition, l, "link is both inhibitor and reset")
+			}
+		}
+	}
+	checkLinks(n.LinksIn)
+	checkLinks(n.LinksOut)
+
+	for _, t := range n.Transitions {
+		if len(t.InPlaces) == 0 && len(t.InPlacesWithInfo) == 0 {
+			if len(t.InPlacesWithInhibitor) == 0 {
+				add(SeverityError, "", t.Name, nil, "no input places, transition is always enabled")
+			} else {
+				add(SeverityWarning, "", t.Name, nil, "only inhibitor arcs, transition fires until its outputs block it")
+			}
+		}
+
+		if len(t.OutPlaces) == 0 {
+			add(SeverityWarning, "", t.Name, nil, "no output places")
+		}
+	}
+
+	// transitions of the same priority taking tokens from one place are resolved by DoConflict
+	for i, p := range n.Places {
+		var conflict []*Transition
+		for _, t := range n.Transitions {
+			for _, in := range t.InPlaces {
+				if in == i {
+					conflict = append(conflict, t)
+					break
+				}
+			}
+		}
+
+		groups := make(map[int][]*Transition)
+		var priorities []int
+		for _, t := range conflict {
+			if _, ok := groups[t.Priority]; !ok {
+				priorities = append(priorities, t.Priority)
+			}
+			groups[t.Priority] = append(groups[t.Priority], t)
+		}
+
+		for _, priority := range priorities {
+			group := groups[priority]
+			if len(group) < 2 {
+				continue
+			}
+
+			var names []string
+			explicit := 0
+			sum := 0.0
+			for _, t := range group {
+				names = append(names, t.Name)
+				if t.Probability != 1 {
+					explicit++
+					sum += t.Probability
+				}
+			}
+
+			if explicit == 0 {
+				continue
+			}
+
+			if explicit < len(group) {
+				add(SeverityWarning, p.Name, "", nil, "conflicting transitions %s of priority %d mix default and explicit probabilities",
+					strings.Join(names, ", "), priority)
+			} else if math.Abs(sum-1) > 1e-9 {
+				add(SeverityWarning, p.Name, "", nil, "probabilities of conflicting transitions %s of priority %d sum to %f",
+					strings.Join(names, ", "), priority, sum)
+			}
+		}
+	}
+
+	return ps
+}
+
+type ValidationError struct {
+	Net      string
+	Problems Problems // errors only, warnings are left to Validate
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("net %s is invalid:\n%s", e.Net, e.Problems.String())
+}
+
+// BuildChecked is Build that fails on structural errors of the net
+func (n *Net) BuildChecked(name string, places []*Place, transitions []*Transition, linksIn []*Linker, linksOut []*Linker) (Net, error) {
+	net := n.Build(name, places, transitions, linksIn, linksOut)
+	if ps := n.Validate(); ps.HasErrors() {
+		return net, &ValidationError{Net: name, Problems: ps.Errors()}
+	}
+
+	return net, nil
+}
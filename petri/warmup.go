@@ -0,0 +1,108 @@
+package petri
+
+import (
+	"fmt"
+	"math"
+)
+
+// MSER5 is the number of leading observations of the series to delete. The series is averaged
+// in batches of five and the truncation that leaves the smallest standard error of the mean of
+// the remaining batches wins, only the first half of the series may be deleted.
+func MSER5(series []float64) int {
+	const size = 5
+	n := len(series) / size
+	if n < 2 {
+		return 0
+	}
+
+	batches := make([]float64, n)
+	for i := range batches {
+		for _, v := range series[i*size : (i+1)*size] {
+			batches[i] += v / size
+		}
+	}
+
+	// sums of the batches from d on, d going back from the end
+	best, deleted := math.Inf(1), 0
+	sum, sumSq := 0.0, 0.0
+	for d := n - 1; d >= 0; d-- {
+		sum += batches[d]
+		sumSq += batches[d] * batches[d]
+		if d > n/2 {
+			continue
+		}
+
+		k := float64(n - d)
+		mser := math.Max(sumSq-sum*sum/k, 0) / (k * k)
+		if mser <= best {
+			best, deleted = mser, d
+		}
+	}
+
+	return deleted * size
+}
+
+// WarmUpEstimate is what a pilot run suggests as the warm-up time, see EstimateWarmUp
+type WarmUpEstimate struct {
+	Step    float64
+	Series  []float64 // sum of the marks averaged over each step
+	Deleted int       // leading steps MSER-5 deletes
+	WarmUp  float64
+}
+
+// EstimateWarmUp makes a serial pilot run of the model until the time and suggests where its
+// transient ends: the sum of marks of the places (all places of the objects if none are given)
+// is averaged over steps of the width and MSER-5 picks the truncation point. The model is used
+// up by the run, set the warm-up on a fresh one. The step must be positive and fit the time.
+func (m *Model) EstimateWarmUp(timeModeling, step float64, places ...*Place) (*WarmUpEstimate, error) {
+	if !(step > 0) || !(timeModeling >= step) {
+		return nil, fmt.Errorf("step %f doesn't split the time %f", step, timeModeling)
+	}
+
+	if len(places) == 0 {
+		seen := make(map[*Place]bool)
+		for _, obj := range m.Objects {
+			for _, p := range obj.Places {
+				if !seen[p] {
+					seen[p] = true
+					places = append(places, p)
+				}
+			}
+		}
+	}
+
+	e := &WarmUpEstimate{Step: step, Series: make([]float64, int(timeModeling/step))}
+	observe := func(from, to float64) {
+		level := 0.0
+		for _, p := range places {
+			level += p.Mark
+		}
+
+		// marks hold over the step, split it between the intervals it overlaps
+		to = math.Min(to, timeModeling)
+		for from < to {
+			k := int(from / step)
+			if float64(k+1)*step <= from {
+				k++
+			}
+			if k >= len(e.Series) {
+				break
+			}
+
+			end := math.Min(to, float64(k+1)*step)
+			e.Series[k] += level * (end - from) / step
+			from = end
+		}
+	}
+
+	statistics := m.IsStatistics
+	m.IsStatistics = true
+	m.observers = append(m.observers, observe)
+	m.GoRun(timeModeling)
+	m.observers = m.observers[:len(m.observers)-1]
+	m.IsStatistics = statistics
+
+	e.Deleted = MSER5(e.Series)
+	e.WarmUp = float64(e.Deleted) * step
+	return e, nil
+}
@@ -7,7 +7,20 @@ import (
 	"sync"
 )
 
+// GetModelSMOGroupForTestParallel builds the chain of GetModelSMOGroupForTestSerial with ports
+// between the objects in place of the shared places, every object can run in its own goroutine
 func GetModelSMOGroupForTestParallel(numGroups int, numInGroup int, c *petri.GlobalCounter, gtime *petri.GlobalTime, cond *petri.GlobalLocker, channel chan int) *petri.Model {
+	model := GetModelSMOGroupForTestSerial(numGroups, numInGroup, c, gtime, cond, channel)
+	if err := model.ConnectShared(); err != nil {
+		panic(err)
+	}
+
+	return model
+}
+
+// GetModelSMOGroupForTestSerial builds a generator and a chain of SMO groups, neighbours share
+// the place between them. The serial runs take the model as it is.
+func GetModelSMOGroupForTestSerial(numGroups int, numInGroup int, c *petri.GlobalCounter, gtime *petri.GlobalTime, cond *petri.GlobalLocker, channel chan int) *petri.Model {
 	var list []*petri.Simulator
 	var counter *petri.GlobalCounter
 
@@ -28,10 +41,6 @@ func GetModelSMOGroupForTestParallel(numGroups int, numInGroup int, c *petri.Glo
 	}
 
 	list[0].TNet.Places[1] = list[1].TNet.Places[0]
-	list[0].OutT = append(list[0].OutT, list[0].TNet.Transitions[0])
-	list[1].InT = append(list[1].InT, list[1].TNet.Transitions[0])
-	list[0].NextObj = list[1]
-	list[1].PrevObj = list[0]
 
 	if numSMO > 1 {
 		for i := 2; i <= numSMO; i++ {
@@ -39,12 +48,6 @@ func GetModelSMOGroupForTestParallel(numGroups int, numInGroup int, c *petri.Glo
 
 			//group1 = > group2, group2 = > group3,...
 			list[i].TNet.Places[0] = list[i-1].TNet.Places[last]
-
-			lastT := len(list[i-1].TNet.Transitions) - 1
-			list[i-1].OutT = append(list[i-1].OutT, list[i-1].TNet.Transitions[lastT])
-			list[i].InT = append(list[i].InT, list[i].TNet.Transitions[0])
-			list[i-1].NextObj = list[i]
-			list[i].PrevObj = list[i-1]
 		}
 	}
 
@@ -66,19 +69,15 @@ func PrintResultsForAllObjects(model *petri.Model) {
 	var wg sync.WaitGroup
 	for _, e := range model.Objects {
 		wg.Add(1)
-		go func() {
+		go func(e *petri.Simulator) {
 			log.Printf("For SMO %s: tLocal: %f\n", e.Name, e.TimeLocal)
-			if e.PrevObj != nil {
-				if len(e.TimeExternalInput) > 0 {
-					log.Printf("tExternalInput first: %+v\ntExternalInput last: %+v\n", e.TimeExternalInput[0], e.TimeExternalInput[len(e.TimeExternalInput)-1])
-				}
-
+			if len(e.InPorts) > 0 {
 				for j := 0; j < len(e.TNet.Places)/2; j++ {
 					log.Printf("Mean queue in SMO %s %f, mark in position %f", e.Name, e.TNet.Places[2*j].Mean, e.TNet.Places[2*j].Mark)
 				}
 			}
 
 			wg.Done()
-		}()
+		}(e)
 	}
 }